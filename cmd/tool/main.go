@@ -4,10 +4,22 @@ import (
 	"os"
 
 	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/codacy/codacy-semgrep/internal/cli"
 	"github.com/codacy/codacy-semgrep/internal/tool"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fix":
+			os.Exit(cli.Fix(os.Args[2:], os.Stdout, os.Stderr))
+		case "analyze":
+			os.Exit(cli.Analyze(os.Args[2:], os.Stdout, os.Stderr))
+		case "baseline":
+			os.Exit(cli.Baseline(os.Args[2:], os.Stderr))
+		}
+	}
+
 	codacySemgrep := tool.New()
 	retCode := codacy.StartTool(codacySemgrep)
 
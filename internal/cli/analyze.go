@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+)
+
+// Analyze runs the analyze command: the analysis Codacy runs, with the source and the tool configuration
+// directories, the docs and the semgrep-core binary of the flags, like the other commands.
+// The files to analyse are the arguments of the command, or the files of the analysis configuration.
+//
+// Return codes are the same as for an analysis:
+//   - 0 - Analysis completed successfully, with or without issues
+//   - 1 - An error occurred
+//   - 2 - Execution timeout
+func Analyze(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: codacy-semgrep analyze [flags] [files...]")
+		flags.PrintDefaults()
+	}
+	configuration := newRunConfiguration(flags)
+	patterns := flags.String("patterns", "", "Comma-separated ids of the patterns to analyse with, instead of the default ones")
+	configFile := flags.String("config", "", "Analysis configuration file, like the .codacyrc of Codacy, instead of the one of the tool configuration directory")
+	format := flags.String("format", "codacy", "Output format: "+strings.Join(formatNames(), ", "))
+	output := flags.String("output", "-", "File to write the results to, - for the standard output")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	writeResults, err := parseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+
+	var toolExecution codacy.ToolExecution
+	if *configFile != "" {
+		toolExecution, err = newToolExecution(configuration.sourceDir, configuration.docsDir())
+		if err == nil {
+			toolExecution, err = applyAnalysisConfiguration(toolExecution, *configFile)
+		}
+	} else {
+		toolExecution, err = loadToolExecution(*configuration)
+	}
+	if err == nil && *patterns != "" {
+		err = selectPatterns(&toolExecution, strings.Split(*patterns, ","))
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create tool execution: %s\n", err.Error())
+		return 1
+	}
+	if files := flags.Args(); len(files) > 0 {
+		toolExecution.Files = &files
+	}
+
+	ctx, cancel := configuration.context()
+	defer cancel()
+	semgrep, err := configuration.newTool(ctx, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+
+	startTime := time.Now()
+	results, err := semgrep.Run(ctx, toolExecution)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to run the tool: %s\n", err.Error())
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 2
+		}
+		return 1
+	}
+
+	report := analysisReport{
+		toolDefinition: toolExecution.ToolDefinition,
+		docsDir:        configuration.docsDir(),
+		sourceDir:      toolExecution.SourceDir,
+		results:        results,
+		startTime:      startTime,
+		endTime:        time.Now(),
+	}
+	if err := writeOutput(*output, stdout, func(w io.Writer) error { return writeResults(w, report) }); err != nil {
+		fmt.Fprintf(stderr, "Failed to write results: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
+
+// selectPatterns sets the patterns of the tool execution to the patterns of the tool definition with the ids,
+// with their default parameters.
+func selectPatterns(toolExecution *codacy.ToolExecution, patternIDs []string) error {
+	var patterns []codacy.Pattern
+	for _, patternID := range patternIDs {
+		patternID = strings.TrimSpace(patternID)
+		if patternID == "" {
+			continue
+		}
+		if toolExecution.ToolDefinition.Patterns == nil ||
+			!lo.ContainsBy(*toolExecution.ToolDefinition.Patterns, func(p codacy.Pattern) bool { return p.ID == patternID }) {
+			return fmt.Errorf("unknown pattern %s", patternID)
+		}
+		patterns = append(patterns, codacy.Pattern{ID: patternID})
+	}
+	patterns = patternsWithDefaultParameters(toolExecution.ToolDefinition, patterns)
+	toolExecution.Patterns = &patterns
+	return nil
+}
+
+// writeOutput writes to the output file, or to the standard output for -.
+func writeOutput(output string, stdout io.Writer, write func(w io.Writer) error) error {
+	if output == "-" {
+		return write(stdout)
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRulesDefinition = `rules:
+  - id: python.exit
+    languages: [python]
+    severity: WARNING
+    message: Use sys.exit
+    pattern: exit(...)
+  - id: python.eval
+    languages: [python]
+    severity: ERROR
+    message: Avoid eval
+    pattern: eval(...)
+`
+
+// fakeSemgrepOutput is what the fake semgrep-core reports for app.py
+const fakeSemgrepOutput = `{"results": [{"check_id": "python.exit", "path": "app.py", ` +
+	`"start": {"line": 2, "col": 1, "offset": 11}, "end": {"line": 2, "col": 8, "offset": 18}, ` +
+	`"extra": {"message": "Use sys.exit. It flushes the output."}}], "errors": []}`
+
+// fakeSemgrepProHelp is the -help of the fake semgrep-core, with the flags of semgrep-core-proprietary
+const fakeSemgrepProHelp = "Usage: semgrep-core [options]\n  -rules <file>\n  -deep_intra_file\n  -secrets"
+
+// writeAnalyzeFixture writes a tool configuration directory with the docs, a source directory and a fake semgrep-core-proprietary
+// that reports an issue and records its arguments.
+func writeAnalyzeFixture(t *testing.T) (toolConfigurationDir, sourceDir, semgrepBinary string) {
+	return writeAnalyzeFixtureWithHelp(t, fakeSemgrepProHelp)
+}
+
+// writeAnalyzeFixtureWithHelp writes the analyze fixture with a fake semgrep-core that prints the help for -help.
+func writeAnalyzeFixtureWithHelp(t *testing.T, help string) (toolConfigurationDir, sourceDir, semgrepBinary string) {
+	toolConfigurationDir = t.TempDir()
+	docsDir := filepath.Join(toolConfigurationDir, docsDirName)
+	assert.NoError(t, os.MkdirAll(filepath.Join(docsDir, "description"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(docsDir, toolDefinitionFileName), []byte(testToolDefinition), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(docsDir, "rules.yaml"), []byte(testRulesDefinition), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(docsDir, "description", "description.json"), []byte(`[]`), 0o644))
+
+	sourceDir = t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(sourceDir, "app.py"), []byte("import os\nexit(1)\n"), 0o644))
+
+	semgrepBinary = filepath.Join(t.TempDir(), "semgrep-core")
+	script := "#!/bin/sh\nif [ \"$1\" = \"-help\" ]; then\n  printf '" + help + "\\n'\n  exit 0\nfi\n" +
+		"echo \"$@\" >> " + filepath.Join(filepath.Dir(semgrepBinary), "arguments") + "\ncat <<'EOF'\n" + fakeSemgrepOutput + "\nEOF\n"
+	assert.NoError(t, os.WriteFile(semgrepBinary, []byte(script), 0o755))
+	return toolConfigurationDir, sourceDir, semgrepBinary
+}
+
+func TestAnalyze(t *testing.T) {
+	// Arrange
+	toolConfigurationDir, sourceDir, semgrepBinary := writeAnalyzeFixture(t)
+	var stdout, stderr bytes.Buffer
+
+	// Act
+	code := Analyze([]string{"-sourceDir", sourceDir, "-toolConfigLocation", toolConfigurationDir, "-semgrep", semgrepBinary}, &stdout, &stderr)
+
+	// Assert
+	assert.Equal(t, 0, code, stderr.String())
+	expected, err := codacy.Issue{PatternID: "python.exit", File: "app.py", Line: 2, Message: "Use sys.exit."}.ToJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected)+"\n", stdout.String())
+}
+
+func TestAnalyzeWithTextFormatAndOutputFile(t *testing.T) {
+	// Arrange
+	toolConfigurationDir, sourceDir, semgrepBinary := writeAnalyzeFixture(t)
+	output := filepath.Join(t.TempDir(), "results.txt")
+	var stdout, stderr bytes.Buffer
+
+	// Act
+	code := Analyze([]string{
+		"-sourceDir", sourceDir, "-toolConfigLocation", toolConfigurationDir, "-semgrep", semgrepBinary, "-format", "text", "-output", output,
+	}, &stdout, &stderr)
+
+	// Assert
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Empty(t, stdout.String())
+	content, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^app.py:2:1: Use sys.exit. \[python.exit\] fingerprint=[0-9a-f]{64}\n1 issues, 0 file errors\n$`, string(content))
+}
+
+func TestAnalyzeWithPatternsAndFiles(t *testing.T) {
+	// Arrange
+	toolConfigurationDir, sourceDir, semgrepBinary := writeAnalyzeFixture(t)
+	assert.NoError(t, os.WriteFile(filepath.Join(sourceDir, "other.py"), []byte("x = 1\n"), 0o644))
+	var stdout, stderr bytes.Buffer
+
+	// Act
+	code := Analyze([]string{
+		"-sourceDir", sourceDir, "-toolConfigLocation", toolConfigurationDir, "-semgrep", semgrepBinary, "-patterns", "python.eval", "app.py",
+	}, &stdout, &stderr)
+
+	// Assert
+	assert.Equal(t, 0, code, stderr.String())
+	arguments, err := os.ReadFile(filepath.Join(filepath.Dir(semgrepBinary), "arguments"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(string(arguments)), " app.py"), string(arguments))
+	assert.NotContains(t, string(arguments), "other.py")
+}
+
+func TestAnalyzeWithAnalysisConfigurationOfToolConfigurationDir(t *testing.T) {
+	// Arrange
+	toolConfigurationDir, sourceDir, semgrepBinary := writeAnalyzeFixture(t)
+	assert.NoError(t, os.WriteFile(filepath.Join(sourceDir, "other.py"), []byte("x = 1\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(toolConfigurationDir, analysisConfigurationFile), []byte(`{"files": ["other.py"]}`), 0o644))
+	var stdout, stderr bytes.Buffer
+
+	// Act
+	code := Analyze([]string{"-sourceDir", sourceDir, "-toolConfigLocation", toolConfigurationDir, "-semgrep", semgrepBinary}, &stdout, &stderr)
+
+	// Assert
+	assert.Equal(t, 0, code, stderr.String())
+	arguments, err := os.ReadFile(filepath.Join(filepath.Dir(semgrepBinary), "arguments"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(string(arguments)), " other.py"), string(arguments))
+	assert.NotContains(t, string(arguments), "app.py")
+}
+
+func TestAnalyzeWithOpenSourceSemgrepCore(t *testing.T) {
+	// Arrange
+	toolConfigurationDir, sourceDir, semgrepBinary := writeAnalyzeFixtureWithHelp(t, "Usage: semgrep-core [options]\n  -rules <file>")
+	var stdout, stderr bytes.Buffer
+
+	// Act
+	code := Analyze([]string{"-sourceDir", sourceDir, "-toolConfigLocation", toolConfigurationDir, "-semgrep", semgrepBinary}, &stdout, &stderr)
+
+	// Assert
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stderr.String(), "isn't semgrep-core-proprietary")
+	assert.Contains(t, stdout.String(), `"patternId":"python.exit"`)
+	arguments, err := os.ReadFile(filepath.Join(filepath.Dir(semgrepBinary), "arguments"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(arguments), "-secrets")
+}
+
+func TestAnalyzeWithInvalidArguments(t *testing.T) {
+	toolConfigurationDir, sourceDir, semgrepBinary := writeAnalyzeFixture(t)
+	testCases := map[string]struct {
+		args          []string
+		expectedError string
+	}{
+		"unknown format": {
+			args:          []string{"-format", "xml"},
+			expectedError: "unknown output format xml: must be one of codacy, gitlab, sarif, text",
+		},
+		"unknown pattern": {
+			args:          []string{"-patterns", "python.eval,python.unknown"},
+			expectedError: "unknown pattern python.unknown",
+		},
+		"missing configuration file": {
+			args:          []string{"-config", filepath.Join(sourceDir, ".codacyrc")},
+			expectedError: "failed to read analysis configuration file",
+		},
+		"missing docs": {
+			args:          []string{"-toolConfigLocation", t.TempDir()},
+			expectedError: "failed to read tool definition file",
+		},
+		"not semgrep-core": {
+			args:          []string{"-semgrep", "true"},
+			expectedError: "true is not semgrep-core",
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := append([]string{"-sourceDir", sourceDir, "-toolConfigLocation", toolConfigurationDir, "-semgrep", semgrepBinary}, testCase.args...)
+
+			code := Analyze(args, &stdout, &stderr)
+
+			assert.Equal(t, 1, code)
+			assert.Contains(t, stderr.String(), testCase.expectedError)
+		})
+	}
+}
+
+func TestSelectPatterns(t *testing.T) {
+	// Arrange
+	toolExecution, err := newToolExecution("/src", writeToolConfiguration(t, "")+"/docs")
+	assert.NoError(t, err)
+
+	// Act
+	err = selectPatterns(&toolExecution, []string{"python.exit", " ", "python.eval"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &[]codacy.Pattern{
+		{ID: "python.exit", Parameters: []codacy.PatternParameter{{Name: "mode", Default: "strict"}}},
+		{ID: "python.eval"},
+	}, toolExecution.Patterns)
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/codacy/codacy-semgrep/internal/tool"
+)
+
+const defaultBaselineFile = ".semgrep-baseline.json"
+
+// Baseline runs the baseline command: it analyses the source directory like a Codacy analysis and writes
+// its issues to a baseline file, replacing the previous one. Analyses with the baseline only report new issues.
+//
+// Return codes are the same as for an analysis:
+//   - 0 - Baseline written successfully
+//   - 1 - An error occurred
+//   - 2 - Execution timeout
+func Baseline(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("baseline", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configuration := newRunConfiguration(flags)
+	output := flags.String("output", defaultBaselineFile, "File to write the baseline to, relative to the source directory")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	baselineFile := *output
+	if !filepath.IsAbs(baselineFile) {
+		baselineFile = filepath.Join(configuration.sourceDir, baselineFile)
+	}
+
+	toolExecution, err := loadToolExecution(*configuration)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create tool execution: %s\n", err.Error())
+		return 1
+	}
+
+	ctx, cancel := configuration.context()
+	defer cancel()
+	semgrep, err := configuration.newTool(ctx, stderr, tool.WithoutBaseline())
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+	results, err := semgrep.Run(ctx, toolExecution)
+	if err != nil {
+		// A baseline of a partial analysis would report the missing issues as new on the next analysis
+		fmt.Fprintf(stderr, "Failed to run the tool: %s\n", err.Error())
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 2
+		}
+		return 1
+	}
+
+	baseline := tool.NewBaseline(results)
+	if previous, err := tool.ReadBaseline(baselineFile); err == nil {
+		summary := previous.Compare(results)
+		fmt.Fprintf(stderr, "Since the previous baseline: %d new issues, %d fixed issues\n", summary.New, summary.Fixed)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(stderr, "Replacing invalid baseline: %s\n", err.Error())
+	}
+
+	if err := baseline.Write(baselineFile); err != nil {
+		fmt.Fprintf(stderr, "Failed to write baseline: %s\n", err.Error())
+		return 1
+	}
+	fmt.Fprintf(stderr, "Wrote baseline with %d issues to %s\n", len(baseline.Issues), baselineFile)
+	return 0
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codacy/codacy-semgrep/internal/tool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseline(t *testing.T) {
+	// Arrange
+	toolConfigurationDir, sourceDir, semgrepBinary := writeAnalyzeFixture(t)
+	docsDir := filepath.Join(toolConfigurationDir, docsDirName)
+	var stderr bytes.Buffer
+
+	// Act
+	code := Baseline([]string{"-sourceDir", sourceDir, "-docsDir", docsDir, "-semgrep", semgrepBinary}, &stderr)
+
+	// Assert
+	assert.Equal(t, 0, code, stderr.String())
+	baseline, err := tool.ReadBaseline(filepath.Join(sourceDir, defaultBaselineFile))
+	assert.NoError(t, err)
+	assert.Len(t, baseline.Issues, 1)
+}
+
+func TestBaselineWithTimeout(t *testing.T) {
+	// Arrange
+	toolConfigurationDir, sourceDir, _ := writeAnalyzeFixture(t)
+	// A semgrep that doesn't finish before TIMEOUT_SECONDS
+	semgrepBinary := filepath.Join(t.TempDir(), "semgrep-core")
+	script := "#!/bin/sh\nif [ \"$1\" = \"-help\" ]; then\n  printf '" + fakeSemgrepProHelp + "\\n'\n  exit 0\nfi\nsleep 10\n"
+	assert.NoError(t, os.WriteFile(semgrepBinary, []byte(script), 0o755))
+	t.Setenv("TIMEOUT_SECONDS", "1")
+	var stderr bytes.Buffer
+
+	// Act
+	code := Baseline([]string{"-sourceDir", sourceDir, "-toolConfigLocation", toolConfigurationDir, "-semgrep", semgrepBinary}, &stderr)
+
+	// Assert
+	assert.Equal(t, 2, code, stderr.String())
+	assert.Contains(t, stderr.String(), "Failed to run the tool")
+	assert.NoFileExists(t, filepath.Join(sourceDir, defaultBaselineFile))
+}
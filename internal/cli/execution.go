@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/codacy/codacy-semgrep/internal/tool"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTimeout              = 15 * time.Minute
+	defaultSourceDir            = "."
+	defaultToolConfigurationDir = "/"
+	docsDirName                 = "docs"
+	toolDefinitionFileName      = "patterns.json"
+	analysisConfigurationFile   = ".codacyrc"
+)
+
+// semgrepCoreBinaries are the semgrep-core binaries the analysis can run with, from the one Codacy runs.
+var semgrepCoreBinaries = []string{"semgrep-core-proprietary", "semgrep-core"}
+
+// runConfiguration is the configuration shared by the commands of the tool, set the same way as for a Codacy analysis:
+// the sourceDir and toolConfigLocation flags and the TIMEOUT_SECONDS and DEBUG environment variables,
+// with the docsDir and semgrep flags to run it outside the container of the tool.
+type runConfiguration struct {
+	sourceDir            string
+	toolConfigurationDir string
+	docsDirOverride      string
+	semgrepBinary        string
+	timeout              time.Duration
+}
+
+// newRunConfiguration adds the flags of the run configuration to the flags of a command and reads its environment variables.
+func newRunConfiguration(flags *flag.FlagSet) *runConfiguration {
+	configuration := &runConfiguration{timeout: environmentTimeout()}
+	flags.StringVar(&configuration.sourceDir, "sourceDir", defaultSourceDir, "Directory with the source files to analyse")
+	flags.StringVar(&configuration.toolConfigurationDir, "toolConfigLocation", defaultToolConfigurationDir, "Directory of the tool's configuration")
+	flags.StringVar(&configuration.docsDirOverride, "docsDir", "", "Directory of the docs of the tool, generated by docgen (default <toolConfigLocation>/docs)")
+	flags.StringVar(&configuration.semgrepBinary, "semgrep", defaultSemgrepBinary(), "semgrep-core binary to run, a name in the PATH or a path")
+	configureLogging()
+	return configuration
+}
+
+// docsDir is the directory of the docs of the tool, in the tool configuration directory unless it is set.
+func (c runConfiguration) docsDir() string {
+	if c.docsDirOverride != "" {
+		return c.docsDirOverride
+	}
+	return filepath.Join(c.toolConfigurationDir, docsDirName)
+}
+
+// newTool creates the tool the commands run, with the docs and the semgrep-core binary of the configuration.
+// Unlike a Codacy analysis, an analysis that runs out of time fails instead of reporting partial results.
+func (c runConfiguration) newTool(ctx context.Context, stderr io.Writer, options ...tool.Option) (codacy.Tool, error) {
+	engine, err := tool.ProbeSemgrepEngine(ctx, c.semgrepBinary)
+	if err != nil {
+		return nil, err
+	}
+	if !engine.Pro {
+		fmt.Fprintf(stderr, "Warning: %s isn't semgrep-core-proprietary, so the issues of the secrets rules and of the Pro engine aren't reported like in Codacy\n", engine.Binary)
+	}
+	options = append([]tool.Option{tool.WithDocsDir(c.docsDir()), tool.WithSemgrepEngine(engine), tool.WithFailOnTimeout()}, options...)
+	return tool.New(options...), nil
+}
+
+// defaultSemgrepBinary is the first semgrep-core binary in the PATH, or the binary of the container of the tool.
+func defaultSemgrepBinary() string {
+	for _, binary := range semgrepCoreBinaries {
+		if _, err := exec.LookPath(binary); err == nil {
+			return binary
+		}
+	}
+	return tool.DefaultSemgrepBinary
+}
+
+// context returns the context a command runs in: it ends at the timeout, or when the command is interrupted,
+// so the semgrep processes, which aren't in the process group of the terminal, are killed with it.
+func (c runConfiguration) context() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// environmentTimeout returns the timeout of TIMEOUT_SECONDS, or the default one.
+func environmentTimeout() time.Duration {
+	if seconds, err := strconv.Atoi(os.Getenv("TIMEOUT_SECONDS")); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultTimeout
+}
+
+// configureLogging logs debug messages when DEBUG is set.
+func configureLogging() {
+	if debug, err := strconv.ParseBool(os.Getenv("DEBUG")); err == nil && debug {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+}
+
+// loadToolExecution builds the tool execution like a Codacy analysis does, from the tool definition
+// and the optional .codacyrc of the tool configuration directory.
+func loadToolExecution(configuration runConfiguration) (codacy.ToolExecution, error) {
+	toolExecution, err := newToolExecution(configuration.sourceDir, configuration.docsDir())
+	if err != nil {
+		return toolExecution, err
+	}
+
+	analysisConfigurationLocation := filepath.Join(configuration.toolConfigurationDir, analysisConfigurationFile)
+	if _, err := os.Stat(analysisConfigurationLocation); err != nil {
+		// Without analysis configuration, all files are analysed with the default patterns
+		return toolExecution, nil
+	}
+	return applyAnalysisConfiguration(toolExecution, analysisConfigurationLocation)
+}
+
+// newToolExecution creates the execution of all the files of the source directory with the default patterns
+// of the tool definition of the docs directory.
+func newToolExecution(sourceDir, docsDir string) (codacy.ToolExecution, error) {
+	toolDefinitionLocation := filepath.Join(docsDir, toolDefinitionFileName)
+	toolDefinitionContent, err := os.ReadFile(toolDefinitionLocation)
+	if err != nil {
+		return codacy.ToolExecution{}, fmt.Errorf("failed to read tool definition file: %s\n%w", toolDefinitionLocation, err)
+	}
+	toolDefinition := codacy.ToolDefinition{}
+	if err := json.Unmarshal(toolDefinitionContent, &toolDefinition); err != nil {
+		return codacy.ToolExecution{}, fmt.Errorf("failed to parse tool definition file: %s\n%w", toolDefinitionLocation, err)
+	}
+
+	return codacy.ToolExecution{
+		ToolDefinition: toolDefinition,
+		SourceDir:      sourceDir,
+	}, nil
+}
+
+// applyAnalysisConfiguration sets the files and the patterns of an analysis configuration file, like .codacyrc,
+// to the tool execution.
+func applyAnalysisConfiguration(toolExecution codacy.ToolExecution, analysisConfigurationLocation string) (codacy.ToolExecution, error) {
+	analysisConfigurationContent, err := os.ReadFile(analysisConfigurationLocation)
+	if err != nil {
+		return toolExecution, fmt.Errorf("failed to read analysis configuration file: %s\n%w", analysisConfigurationLocation, err)
+	}
+	analysisConfiguration := codacy.AnalysisConfiguration{}
+	if err := json.Unmarshal(analysisConfigurationContent, &analysisConfiguration); err != nil {
+		return toolExecution, fmt.Errorf("failed to parse analysis configuration file: %s\n%w", analysisConfigurationLocation, err)
+	}
+	toolExecution.Files = analysisConfiguration.Files
+
+	if analysisConfiguration.Tools != nil {
+		configuredTool, found := lo.Find(*analysisConfiguration.Tools, func(tool codacy.ToolDefinition) bool {
+			return tool.Name == toolExecution.ToolDefinition.Name
+		})
+		if found && configuredTool.Patterns != nil {
+			patterns := patternsWithDefaultParameters(toolExecution.ToolDefinition, *configuredTool.Patterns)
+			toolExecution.Patterns = &patterns
+		}
+	}
+	return toolExecution, nil
+}
+
+// patternsWithDefaultParameters adds to the configured patterns the parameters they are missing, with their defaults.
+func patternsWithDefaultParameters(toolDefinition codacy.ToolDefinition, configuredPatterns []codacy.Pattern) []codacy.Pattern {
+	definitionPatterns := map[string]codacy.Pattern{}
+	if toolDefinition.Patterns != nil {
+		definitionPatterns = lo.KeyBy(*toolDefinition.Patterns, func(pattern codacy.Pattern) string {
+			return pattern.ID
+		})
+	}
+
+	return lo.Map(configuredPatterns, func(pattern codacy.Pattern, _ int) codacy.Pattern {
+		for _, parameter := range definitionPatterns[pattern.ID].Parameters {
+			if !lo.ContainsBy(pattern.Parameters, func(p codacy.PatternParameter) bool { return p.Name == parameter.Name }) {
+				pattern.Parameters = append(pattern.Parameters, parameter)
+			}
+		}
+		return pattern
+	})
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+const testToolDefinition = `{
+  "name": "semgrep",
+  "version": "1.0.0",
+  "patterns": [
+    {"patternId": "python.exit", "parameters": [{"name": "mode", "default": "strict"}]},
+    {"patternId": "python.eval"}
+  ]
+}`
+
+func writeToolConfiguration(t *testing.T, codacyrc string) string {
+	toolConfigurationDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(toolConfigurationDir, "docs"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(toolConfigurationDir, docsDirName, toolDefinitionFileName), []byte(testToolDefinition), 0o644))
+	if codacyrc != "" {
+		assert.NoError(t, os.WriteFile(filepath.Join(toolConfigurationDir, analysisConfigurationFile), []byte(codacyrc), 0o644))
+	}
+	return toolConfigurationDir
+}
+
+func TestLoadToolExecution(t *testing.T) {
+	// Arrange
+	toolConfigurationDir := writeToolConfiguration(t, `{
+  "files": ["main.py"],
+  "tools": [{"name": "semgrep", "patterns": [{"patternId": "python.exit"}]}]
+}`)
+
+	// Act
+	toolExecution, err := loadToolExecution(runConfiguration{sourceDir: "/src", toolConfigurationDir: toolConfigurationDir})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "/src", toolExecution.SourceDir)
+	assert.Equal(t, "semgrep", toolExecution.ToolDefinition.Name)
+	assert.Equal(t, &[]string{"main.py"}, toolExecution.Files)
+	assert.Equal(t, &[]codacy.Pattern{
+		{ID: "python.exit", Parameters: []codacy.PatternParameter{{Name: "mode", Default: "strict"}}},
+	}, toolExecution.Patterns)
+}
+
+func TestLoadToolExecutionWithoutAnalysisConfiguration(t *testing.T) {
+	// Arrange
+	toolConfigurationDir := writeToolConfiguration(t, "")
+
+	// Act
+	toolExecution, err := loadToolExecution(runConfiguration{sourceDir: "/src", toolConfigurationDir: toolConfigurationDir})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Nil(t, toolExecution.Files)
+	assert.Nil(t, toolExecution.Patterns)
+	assert.Len(t, *toolExecution.ToolDefinition.Patterns, 2)
+}
+
+func TestLoadToolExecutionWithoutToolDefinition(t *testing.T) {
+	// Act
+	_, err := loadToolExecution(runConfiguration{sourceDir: "/src", toolConfigurationDir: t.TempDir()})
+
+	// Assert
+	assert.ErrorContains(t, err, "failed to read tool definition file")
+}
+
+func TestRunConfigurationContextEndsOnSignal(t *testing.T) {
+	// Arrange
+	ctx, cancel := runConfiguration{timeout: time.Minute}.context()
+	defer cancel()
+
+	// Act
+	process, err := os.FindProcess(os.Getpid())
+	assert.NoError(t, err)
+	assert.NoError(t, process.Signal(syscall.SIGTERM))
+
+	// Assert
+	select {
+	case <-ctx.Done():
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the context to end on SIGTERM")
+	}
+}
+
+func TestNewRunConfiguration(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		configuration := newRunConfiguration(flags)
+
+		assert.NoError(t, flags.Parse(nil))
+
+		assert.Equal(t, ".", configuration.sourceDir)
+		assert.Equal(t, filepath.Join("/", "docs"), configuration.docsDir())
+	})
+	t.Run("docs in the tool configuration directory", func(t *testing.T) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		configuration := newRunConfiguration(flags)
+
+		assert.NoError(t, flags.Parse([]string{"-toolConfigLocation", "/config", "-semgrep", "/opt/semgrep-core"}))
+
+		assert.Equal(t, filepath.Join("/config", "docs"), configuration.docsDir())
+		assert.Equal(t, "/opt/semgrep-core", configuration.semgrepBinary)
+	})
+	t.Run("docs directory", func(t *testing.T) {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		configuration := newRunConfiguration(flags)
+
+		assert.NoError(t, flags.Parse([]string{"-toolConfigLocation", "/config", "-docsDir", "docs"}))
+
+		assert.Equal(t, "docs", configuration.docsDir())
+	})
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/codacy/codacy-semgrep/internal/tool"
+)
+
+// Fix runs the fix command: it analyses the source directory like a Codacy analysis and applies the fixes
+// of the issues found, or writes them to a patch. Fixes that conflict with others are skipped and reported.
+//
+// Return codes are the same as for an analysis:
+//   - 0 - Fixes applied or written successfully
+//   - 1 - An error occurred
+//   - 2 - Execution timeout
+func Fix(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fix", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configuration := newRunConfiguration(flags)
+	apply := flags.Bool("apply", false, "Apply the fixes to the files of the source directory instead of writing a patch")
+	patchFile := flags.String("patch", "-", "File to write the patch with the fixes to, - for the standard output")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	toolExecution, err := loadToolExecution(*configuration)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create tool execution: %s\n", err.Error())
+		return 1
+	}
+
+	ctx, cancel := configuration.context()
+	defer cancel()
+	semgrep, err := configuration.newTool(ctx, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 1
+	}
+	results, err := semgrep.Run(ctx, toolExecution)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to run the tool: %s\n", err.Error())
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 2
+		}
+		return 1
+	}
+
+	plan, err := tool.PlanFixes(configuration.sourceDir, results)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to plan fixes: %s\n", err.Error())
+		return 1
+	}
+
+	if *apply {
+		err = plan.Apply()
+	} else {
+		err = writeOutput(*patchFile, stdout, plan.WriteDiff)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to write fixes: %s\n", err.Error())
+		return 1
+	}
+
+	reportFixes(plan, *apply, stderr)
+	return 0
+}
+
+func reportFixes(plan tool.FixPlan, applied bool, w io.Writer) {
+	action := "Wrote"
+	if applied {
+		action = "Applied"
+	}
+	fmt.Fprintf(w, "%s %d fixes to %d files\n", action, plan.FixCount(), len(plan.Files))
+	for _, skipped := range plan.Skipped {
+		fmt.Fprintf(w, "Skipped fix of %s at %s:%d:%d: %s\n", skipped.Issue.PatternID, skipped.Issue.File,
+			skipped.Issue.Range.Start.Line, skipped.Issue.Range.Start.Col, skipped.Reason)
+	}
+}
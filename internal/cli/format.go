@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/codacy/codacy-semgrep/internal/docgen"
+	"github.com/codacy/codacy-semgrep/internal/tool"
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// analysisReport is what an output format writes: the results of an analysis and what they were analysed with.
+type analysisReport struct {
+	toolDefinition codacy.ToolDefinition
+	// docsDir has the rules and the descriptions of the patterns, for the formats that describe them
+	docsDir   string
+	sourceDir string
+	results   []codacy.Result
+	// startTime and endTime are when the analysis ran
+	startTime time.Time
+	endTime   time.Time
+}
+
+// issues are the issues of the results.
+func (r analysisReport) issues() []tool.Issue {
+	return lo.FilterMap(r.results, func(result codacy.Result, _ int) (tool.Issue, bool) {
+		issue, ok := result.(tool.Issue)
+		return issue, ok
+	})
+}
+
+// patternDocs describes the patterns of an analysis, by id, for the formats that describe them.
+type patternDocs struct {
+	patterns     map[string]codacy.Pattern
+	descriptions map[string]codacy.PatternDescription
+	// metadata is the metadata of the rules, like their CWE and OWASP entries
+	metadata map[string]docgen.SemgrepRuleMetadata
+}
+
+// loadPatternDocs reads the description of the patterns of the tool definition of a report from the docs of the tool.
+func loadPatternDocs(report analysisReport) (patternDocs, error) {
+	docs := patternDocs{patterns: map[string]codacy.Pattern{}}
+	if report.toolDefinition.Patterns != nil {
+		docs.patterns = lo.KeyBy(*report.toolDefinition.Patterns, func(pattern codacy.Pattern) string { return pattern.ID })
+	}
+	descriptions, err := tool.LoadPatternDescriptions(report.docsDir)
+	if err != nil {
+		return docs, err
+	}
+	docs.descriptions = lo.KeyBy(*descriptions, func(description codacy.PatternDescription) string { return description.PatternID })
+	docs.metadata, err = loadRulesMetadata(report.docsDir)
+	return docs, err
+}
+
+// loadRulesMetadata reads the metadata of the rules of the docs directory, by rule id.
+// There is no metadata when the docs directory has no rules.
+func loadRulesMetadata(docsDir string) (map[string]docgen.SemgrepRuleMetadata, error) {
+	rulesLocation := filepath.Join(docsDir, "rules.yaml")
+	content, err := os.ReadFile(rulesLocation)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]docgen.SemgrepRuleMetadata{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %s\n%w", rulesLocation, err)
+	}
+	rules := docgen.SemgrepConfig{}
+	if err := yaml.Unmarshal(content, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %s\n%w", rulesLocation, err)
+	}
+	return lo.SliceToMap(rules.Rules, func(rule docgen.SemgrepRule) (string, docgen.SemgrepRuleMetadata) {
+		return rule.ID, rule.Metadata
+	}), nil
+}
+
+// resultsWriter writes the results of an analysis in an output format.
+type resultsWriter func(w io.Writer, report analysisReport) error
+
+// resultsWriters are the output formats of the analyze command, by name.
+var resultsWriters = map[string]resultsWriter{
+	"codacy": writeCodacyResults,
+	"gitlab": writeGitLabResults,
+	"sarif":  writeSarifResults,
+	"text":   writeTextResults,
+}
+
+// formatNames returns the names of the output formats, sorted.
+func formatNames() []string {
+	names := lo.Keys(resultsWriters)
+	sort.Strings(names)
+	return names
+}
+
+// writeCodacyResults writes the results like a Codacy analysis does, a JSON result per line.
+func writeCodacyResults(w io.Writer, report analysisReport) error {
+	out := bufio.NewWriter(w)
+	for _, result := range codacy.Results(report.results).ToJSON() {
+		out.WriteString(result + "\n")
+	}
+	return out.Flush()
+}
+
+// writeTextResults writes the results for people to read, a result per line, like compilers report errors.
+// Issues end with their fingerprint, to copy into a baseline.
+func writeTextResults(w io.Writer, report analysisReport) error {
+	out := bufio.NewWriter(w)
+	issueCount, fileErrorCount := 0, 0
+	for _, result := range report.results {
+		switch result := result.(type) {
+		case tool.Issue:
+			issueCount++
+			fmt.Fprintf(out, "%s:%d:%d: %s [%s]", result.File, result.Line, result.Range.Start.Col, result.Message, result.PatternID)
+			if result.Fingerprint != "" {
+				fmt.Fprintf(out, " fingerprint=%s", result.Fingerprint)
+			}
+			fmt.Fprintln(out)
+		case codacy.FileError:
+			fileErrorCount++
+			fmt.Fprintf(out, "%s: %s\n", result.File, result.Message)
+		}
+	}
+	fmt.Fprintf(out, "%d issues, %d file errors\n", issueCount, fileErrorCount)
+	return out.Flush()
+}
+
+// parseFormat returns the writer of an output format.
+func parseFormat(format string) (resultsWriter, error) {
+	writer, found := resultsWriters[strings.ToLower(format)]
+	if !found {
+		return nil, fmt.Errorf("unknown output format %s: must be one of %s", format, strings.Join(formatNames(), ", "))
+	}
+	return writer, nil
+}
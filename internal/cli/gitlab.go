@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/codacy/codacy-semgrep/internal/docgen"
+	"github.com/codacy/codacy-semgrep/internal/tool"
+)
+
+// https://gitlab.com/gitlab-org/security-products/security-report-schemas/-/blob/master/dist/sast-report-format.json
+const (
+	gitLabReportVersion = "15.0.7"
+	// gitLabTimeFormat is the format of the times of the report, without a time zone
+	gitLabTimeFormat = "2006-01-02T15:04:05"
+	gitLabVendor     = "Codacy"
+	gitLabAnalyzerID = "codacy-semgrep"
+)
+
+// gitLabIDNamespace is the namespace of the name-based UUIDs of the vulnerabilities, 0d2bfb9e-56a1-4e4c-9d4f-3c2a8c1f5e7b
+var gitLabIDNamespace = [16]byte{0x0d, 0x2b, 0xfb, 0x9e, 0x56, 0xa1, 0x4e, 0x4c, 0x9d, 0x4f, 0x3c, 0x2a, 0x8c, 0x1f, 0x5e, 0x7b}
+
+// cweNumber is the number of a CWE entry, like 89 in "CWE-89: Improper Neutralization of Special Elements..."
+var cweNumber = regexp.MustCompile(`CWE-(\d+)`)
+
+type gitLabReport struct {
+	Version         string                `json:"version"`
+	Scan            gitLabScan            `json:"scan"`
+	Vulnerabilities []gitLabVulnerability `json:"vulnerabilities"`
+}
+
+type gitLabScan struct {
+	Analyzer  gitLabScanner   `json:"analyzer"`
+	Scanner   gitLabScanner   `json:"scanner"`
+	Type      string          `json:"type"`
+	StartTime string          `json:"start_time"`
+	EndTime   string          `json:"end_time"`
+	Status    string          `json:"status"`
+	Messages  []gitLabMessage `json:"messages,omitempty"`
+}
+
+type gitLabScanner struct {
+	ID      string           `json:"id"`
+	Name    string           `json:"name"`
+	Version string           `json:"version"`
+	Vendor  gitLabVendorName `json:"vendor"`
+}
+
+type gitLabVendorName struct {
+	Name string `json:"name"`
+}
+
+type gitLabMessage struct {
+	Level string `json:"level"`
+	Value string `json:"value"`
+}
+
+type gitLabVulnerability struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Severity    string             `json:"severity"`
+	Solution    string             `json:"solution,omitempty"`
+	Location    gitLabLocation     `json:"location"`
+	Identifiers []gitLabIdentifier `json:"identifiers"`
+}
+
+type gitLabLocation struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line,omitempty"`
+}
+
+type gitLabIdentifier struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	URL   string `json:"url,omitempty"`
+}
+
+// writeGitLabResults writes the results as a GitLab SAST report, like the gl-sast-report.json of GitLab CI jobs.
+// File errors are warnings of the scan, since the report has no place for them.
+func writeGitLabResults(w io.Writer, report analysisReport) error {
+	issues := report.issues()
+	docs := patternDocs{}
+	if len(issues) > 0 {
+		var err error
+		if docs, err = loadPatternDocs(report); err != nil {
+			return err
+		}
+	}
+
+	// The analyzer is this tool, and the scanner is semgrep, both with the version of semgrep
+	scanner := gitLabScanner{
+		ID:      report.toolDefinition.Name,
+		Name:    report.toolDefinition.Name,
+		Version: report.toolDefinition.Version,
+		Vendor:  gitLabVendorName{Name: gitLabVendor},
+	}
+	analyzer := scanner
+	analyzer.ID, analyzer.Name = gitLabAnalyzerID, gitLabAnalyzerID
+	gitLab := gitLabReport{
+		Version: gitLabReportVersion,
+		Scan: gitLabScan{
+			Analyzer:  analyzer,
+			Scanner:   scanner,
+			Type:      "sast",
+			StartTime: formatGitLabTime(report.startTime),
+			EndTime:   formatGitLabTime(report.endTime),
+			Status:    "success",
+		},
+		Vulnerabilities: make([]gitLabVulnerability, 0, len(issues)),
+	}
+
+	for _, issue := range issues {
+		gitLab.Vulnerabilities = append(gitLab.Vulnerabilities, newGitLabVulnerability(issue, docs))
+	}
+	for _, result := range report.results {
+		if fileError, ok := result.(codacy.FileError); ok {
+			gitLab.Scan.Messages = append(gitLab.Scan.Messages, gitLabMessage{Level: "warn", Value: fileError.File + ": " + fileError.Message})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(gitLab)
+}
+
+func newGitLabVulnerability(issue tool.Issue, docs patternDocs) gitLabVulnerability {
+	name := issue.Message
+	if description, found := docs.descriptions[issue.PatternID]; found && description.Title != "" {
+		name = description.Title
+	}
+	vulnerability := gitLabVulnerability{
+		ID:          gitLabVulnerabilityID(issue),
+		Name:        name,
+		Description: issue.Message,
+		Severity:    gitLabSeverity(docs.patterns[issue.PatternID].Level),
+		Location:    gitLabLocation{File: issue.File, StartLine: issue.Line},
+		Identifiers: gitLabIdentifiers(issue.PatternID, docs.metadata[issue.PatternID]),
+	}
+	if issue.Range.End.Line > issue.Line {
+		vulnerability.Location.EndLine = issue.Range.End.Line
+	}
+	if issue.Fix != "" {
+		vulnerability.Solution = "Replace the code with `" + issue.Fix + "`."
+	}
+	return vulnerability
+}
+
+// gitLabVulnerabilityID is a version 5 UUID (RFC 4122, SHA-1 based) with the fingerprint of an issue as its name,
+// so a vulnerability keeps its id across pipelines while its code doesn't change.
+// Issues without a fingerprint get one from their rule, their file and their line.
+func gitLabVulnerabilityID(issue tool.Issue) string {
+	fingerprint := issue.Fingerprint
+	if fingerprint == "" {
+		fingerprint = fmt.Sprintf("%s\x00%s\x00%d", issue.PatternID, issue.File, issue.Line)
+	}
+	hash := sha1.New()
+	hash.Write(gitLabIDNamespace[:])
+	hash.Write([]byte(fingerprint))
+	id := hash.Sum(nil)[:16]
+	// Version 5 and RFC 4122 variant bits
+	id[6] = id[6]&0x0f | 0x50
+	id[8] = id[8]&0x3f | 0x80
+	hexID := hex.EncodeToString(id)
+	return strings.Join([]string{hexID[0:8], hexID[8:12], hexID[12:16], hexID[16:20], hexID[20:32]}, "-")
+}
+
+// gitLabIdentifiers are the id of the rule of an issue, and the CWE and OWASP entries of the rule.
+// The rule comes first, since GitLab uses the first identifier to tell vulnerabilities apart.
+func gitLabIdentifiers(ruleID string, metadata docgen.SemgrepRuleMetadata) []gitLabIdentifier {
+	identifiers := []gitLabIdentifier{{Type: "semgrep_id", Name: ruleID, Value: ruleID}}
+	for _, cwe := range metadata.CWEs {
+		match := cweNumber.FindStringSubmatch(cwe)
+		if match == nil {
+			continue
+		}
+		// Without leading zeros, like in CWE-089
+		number, _ := strconv.Atoi(match[1])
+		identifiers = append(identifiers, gitLabIdentifier{
+			Type:  "cwe",
+			Name:  fmt.Sprintf("CWE-%d", number),
+			Value: strconv.Itoa(number),
+			URL:   fmt.Sprintf("https://cwe.mitre.org/data/definitions/%d.html", number),
+		})
+	}
+	for _, owasp := range metadata.OWASP {
+		value, _, _ := strings.Cut(owasp, " - ")
+		identifiers = append(identifiers, gitLabIdentifier{Type: "owasp", Name: owasp, Value: strings.TrimSpace(value)})
+	}
+	return identifiers
+}
+
+// gitLabSeverity is the GitLab severity of a Codacy level.
+func gitLabSeverity(level string) string {
+	switch docgen.Level(level) {
+	case docgen.Critical:
+		return "High"
+	case docgen.Medium:
+		return "Medium"
+	case docgen.Low:
+		return "Low"
+	default:
+		return "Unknown"
+	}
+}
+
+// formatGitLabTime formats a time in UTC, or the current time when it isn't set.
+func formatGitLabTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format(gitLabTimeFormat)
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/codacy/codacy-semgrep/internal/docgen"
+	"github.com/codacy/codacy-semgrep/internal/tool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteGitLabResults(t *testing.T) {
+	// Arrange
+	docsDir := writeSarifDocs(t)
+	patterns := []codacy.Pattern{{ID: "python.sql-injection", Level: "Error", Category: "Security"}}
+	startTime := time.Date(2024, 5, 2, 10, 30, 0, 0, time.UTC)
+	report := analysisReport{
+		toolDefinition: codacy.ToolDefinition{Name: "semgrep", Version: "1.78.0", Patterns: &patterns},
+		docsDir:        docsDir,
+		sourceDir:      "/src",
+		startTime:      startTime,
+		endTime:        startTime.Add(time.Minute),
+		results: []codacy.Result{
+			tool.Issue{
+				Issue:       codacy.Issue{PatternID: "python.sql-injection", File: "app/db.py", Line: 9, Message: "Detected SQL injection."},
+				Range:       tool.Range{Start: tool.Position{Line: 9, Col: 5}, End: tool.Position{Line: 11, Col: 2}},
+				Fix:         "execute(query, params)",
+				Fingerprint: "abc123",
+			},
+			tool.Issue{Issue: codacy.Issue{PatternID: "org.custom", File: "app/main.py", Line: 2, Message: "Custom"}},
+			codacy.FileError{File: "app/big.py", Message: "Timeout"},
+		},
+	}
+	var output bytes.Buffer
+
+	// Act
+	err := writeGitLabResults(&output, report)
+
+	// Assert
+	assert.NoError(t, err)
+	var gitLab gitLabReport
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &gitLab))
+	assert.Equal(t, "15.0.7", gitLab.Version)
+	assert.Equal(t, gitLabScan{
+		Analyzer:  gitLabScanner{ID: "codacy-semgrep", Name: "codacy-semgrep", Version: "1.78.0", Vendor: gitLabVendorName{Name: "Codacy"}},
+		Scanner:   gitLabScanner{ID: "semgrep", Name: "semgrep", Version: "1.78.0", Vendor: gitLabVendorName{Name: "Codacy"}},
+		Type:      "sast",
+		StartTime: "2024-05-02T10:30:00",
+		EndTime:   "2024-05-02T10:31:00",
+		Status:    "success",
+		Messages:  []gitLabMessage{{Level: "warn", Value: "app/big.py: Timeout"}},
+	}, gitLab.Scan)
+
+	assert.Len(t, gitLab.Vulnerabilities, 2)
+	vulnerability := gitLab.Vulnerabilities[0]
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), vulnerability.ID)
+	assert.Equal(t, "SQL injection", vulnerability.Name)
+	assert.Equal(t, "Detected SQL injection.", vulnerability.Description)
+	assert.Equal(t, "High", vulnerability.Severity)
+	assert.Equal(t, "Replace the code with `execute(query, params)`.", vulnerability.Solution)
+	assert.Equal(t, gitLabLocation{File: "app/db.py", StartLine: 9, EndLine: 11}, vulnerability.Location)
+	assert.Equal(t, []gitLabIdentifier{
+		{Type: "semgrep_id", Name: "python.sql-injection", Value: "python.sql-injection"},
+		{Type: "cwe", Name: "CWE-89", Value: "89", URL: "https://cwe.mitre.org/data/definitions/89.html"},
+		{Type: "owasp", Name: "A03:2021 - Injection", Value: "A03:2021"},
+	}, vulnerability.Identifiers)
+
+	custom := gitLab.Vulnerabilities[1]
+	assert.Equal(t, "Custom", custom.Name, "Expected the message as the name of a rule without description")
+	assert.Equal(t, "Unknown", custom.Severity)
+	assert.Equal(t, gitLabLocation{File: "app/main.py", StartLine: 2}, custom.Location)
+	assert.Empty(t, custom.Solution)
+}
+
+func TestWriteGitLabResultsWithoutResults(t *testing.T) {
+	// Arrange
+	report := analysisReport{toolDefinition: codacy.ToolDefinition{Name: "semgrep"}, docsDir: t.TempDir()}
+	var output bytes.Buffer
+
+	// Act
+	err := writeGitLabResults(&output, report)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), `"vulnerabilities": []`)
+}
+
+func TestGitLabVulnerabilityID(t *testing.T) {
+	issue := tool.Issue{Issue: codacy.Issue{PatternID: "python.exit", File: "app.py", Line: 2}, Fingerprint: "abc123"}
+	moved := issue
+	moved.Line = 20
+	other := issue
+	other.Fingerprint = "def456"
+
+	// The version 5 UUID of the fingerprint in the namespace, like uuid.uuid5 of Python computes it
+	assert.Equal(t, "0d23aa27-caba-5867-bba8-a03e5ff4393a", gitLabVulnerabilityID(issue))
+	assert.Equal(t, gitLabVulnerabilityID(issue), gitLabVulnerabilityID(moved), "Expected the id to follow the fingerprint, not the line")
+	assert.NotEqual(t, gitLabVulnerabilityID(issue), gitLabVulnerabilityID(other))
+}
+
+func TestGitLabIdentifiersWithPaddedCWE(t *testing.T) {
+	identifiers := gitLabIdentifiers("java.xss", docgen.SemgrepRuleMetadata{CWEs: []string{"CWE-079", "not a CWE"}})
+
+	assert.Equal(t, []gitLabIdentifier{
+		{Type: "semgrep_id", Name: "java.xss", Value: "java.xss"},
+		{Type: "cwe", Name: "CWE-79", Value: "79", URL: "https://cwe.mitre.org/data/definitions/79.html"},
+	}, identifiers)
+}
@@ -0,0 +1,347 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/codacy/codacy-semgrep/internal/docgen"
+	"github.com/codacy/codacy-semgrep/internal/tool"
+	"github.com/samber/lo"
+)
+
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	// sarifSourceRoot is the base of the locations of the results, the source directory
+	sarifSourceRoot = "SRCROOT"
+	// sarifFingerprint is the name of the fingerprint of the results, versioned in case its computation changes
+	sarifFingerprint = "codacySemgrepFingerprint/v1"
+	informationURI   = "https://github.com/codacy/codacy-semgrep"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Invocations        []sarifInvocation                `json:"invocations"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string                   `json:"id"`
+	ShortDescription     *sarifMessage            `json:"shortDescription,omitempty"`
+	FullDescription      *sarifMessage            `json:"fullDescription,omitempty"`
+	Help                 *sarifHelp               `json:"help,omitempty"`
+	DefaultConfiguration sarifRuleConfiguration   `json:"defaultConfiguration"`
+	Properties           *sarifPropertiesWithTags `json:"properties,omitempty"`
+}
+
+type sarifRuleConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifPropertiesWithTags struct {
+	Tags []string `json:"tags"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifHelp struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	CodeFlows           []sarifCodeFlow   `json:"codeFlows,omitempty"`
+	Fixes               []sarifFix        `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// sarifRegion is a range of a file. Lines and columns start at 1, and the end column is excluded, like in semgrep.
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifCodeFlow struct {
+	ThreadFlows []sarifThreadFlow `json:"threadFlows"`
+}
+
+type sarifThreadFlow struct {
+	Locations []sarifThreadFlowLocation `json:"locations"`
+}
+
+type sarifThreadFlowLocation struct {
+	Location sarifLocation `json:"location"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion          `json:"deletedRegion"`
+	InsertedContent sarifArtifactContent `json:"insertedContent"`
+}
+
+type sarifArtifactContent struct {
+	Text string `json:"text"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+// writeSarifResults writes the results as a SARIF 2.1.0 log, for code scanning services like the one of GitHub.
+// The rules of the log are the rules of the issues, described with the docs of the tool.
+// File errors are notifications of the invocation of the tool.
+func writeSarifResults(w io.Writer, report analysisReport) error {
+	issues := report.issues()
+	rules, err := sarifRules(report, issues)
+	if err != nil {
+		return err
+	}
+	ruleIndexes := map[string]int{}
+	for i, rule := range rules {
+		ruleIndexes[rule.ID] = i
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           report.toolDefinition.Name,
+			Version:        report.toolDefinition.Version,
+			InformationURI: informationURI,
+			Rules:          rules,
+		}},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: true}},
+		Results:     []sarifResult{},
+	}
+	if sourceRoot, err := filepath.Abs(report.sourceDir); err == nil {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLocation{
+			sarifSourceRoot: {URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(sourceRoot) + "/"}).String()},
+		}
+	}
+
+	for _, issue := range issues {
+		index := ruleIndexes[issue.PatternID]
+		run.Results = append(run.Results, newSarifResult(issue, index, rules[index].DefaultConfiguration.Level))
+	}
+	for _, result := range report.results {
+		if fileError, ok := result.(codacy.FileError); ok {
+			run.Invocations[0].ToolExecutionNotifications = append(run.Invocations[0].ToolExecutionNotifications, sarifNotification{
+				Level:     "error",
+				Message:   sarifMessage{Text: fileError.Message},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: newSarifArtifactLocation(fileError.File)}}},
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+// sarifRules describes the rules of the issues, sorted by id, with their title, description and help
+// from the docs of the tool, and their level and tags from the patterns of the tool definition and the rules.
+// Rules of the semgrep configuration of the repository only have an id.
+func sarifRules(report analysisReport, issues []tool.Issue) ([]sarifRule, error) {
+	ruleIDs := lo.Uniq(lo.Map(issues, func(issue tool.Issue, _ int) string { return issue.PatternID }))
+	sort.Strings(ruleIDs)
+	if len(ruleIDs) == 0 {
+		return []sarifRule{}, nil
+	}
+
+	docs, err := loadPatternDocs(report)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]sarifRule, 0, len(ruleIDs))
+	for _, id := range ruleIDs {
+		pattern := docs.patterns[id]
+		rule := sarifRule{ID: id, DefaultConfiguration: sarifRuleConfiguration{Level: sarifLevel(pattern.Level)}}
+		if description, found := docs.descriptions[id]; found {
+			rule.ShortDescription = &sarifMessage{Text: description.Title}
+			if description.Description != "" {
+				rule.FullDescription = &sarifMessage{Text: description.Description}
+			}
+		}
+		help, err := os.ReadFile(filepath.Join(report.docsDir, "description", id+".md"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read pattern description: %s\n%w", id, err)
+		}
+		if len(help) > 0 {
+			rule.Help = &sarifHelp{Text: string(help), Markdown: string(help)}
+		}
+		if tags := sarifTags(pattern, docs.metadata[id]); len(tags) > 0 {
+			rule.Properties = &sarifPropertiesWithTags{Tags: tags}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// sarifTags are the category and the subcategory of a pattern, and the CWE and OWASP entries of its rule.
+func sarifTags(pattern codacy.Pattern, metadata docgen.SemgrepRuleMetadata) []string {
+	var tags []string
+	for _, category := range []string{pattern.Category, pattern.SubCategory} {
+		if category != "" {
+			tags = append(tags, strings.ToLower(category))
+		}
+	}
+	tags = append(tags, metadata.CWEs...)
+	tags = append(tags, metadata.OWASP...)
+	return lo.Uniq(tags)
+}
+
+// sarifLevel is the SARIF level of a Codacy level.
+func sarifLevel(level string) string {
+	switch docgen.Level(level) {
+	case docgen.Critical:
+		return "error"
+	case docgen.Low:
+		return "note"
+	default:
+		return "warning"
+	}
+}
+
+func newSarifResult(issue tool.Issue, ruleIndex int, level string) sarifResult {
+	result := sarifResult{
+		RuleID:    issue.PatternID,
+		RuleIndex: ruleIndex,
+		Level:     level,
+		Message:   sarifMessage{Text: issue.Message},
+		Locations: []sarifLocation{newSarifLocation(issue.File, issue.Range, issue.Line)},
+	}
+	if issue.Fingerprint != "" {
+		result.PartialFingerprints = map[string]string{sarifFingerprint: issue.Fingerprint}
+	}
+	if issue.Trace != nil {
+		result.CodeFlows = []sarifCodeFlow{{ThreadFlows: []sarifThreadFlow{{Locations: sarifTraceLocations(issue)}}}}
+	}
+	if issue.Fix != "" {
+		result.Fixes = []sarifFix{{
+			Description: sarifMessage{Text: "Apply the fix of " + issue.PatternID},
+			ArtifactChanges: []sarifArtifactChange{{
+				ArtifactLocation: newSarifArtifactLocation(issue.File),
+				Replacements: []sarifReplacement{{
+					DeletedRegion:   newSarifRegion(issue.Range, issue.Line),
+					InsertedContent: sarifArtifactContent{Text: issue.Fix},
+				}},
+			}},
+		}}
+	}
+	return result
+}
+
+// sarifTraceLocations are the steps of the dataflow trace of an issue, from its source to its sink.
+func sarifTraceLocations(issue tool.Issue) []sarifThreadFlowLocation {
+	var steps []tool.TraceLocation
+	if issue.Trace.Source != nil {
+		steps = append(steps, issue.Trace.Source.Origin())
+	}
+	steps = append(steps, issue.Trace.IntermediateVars...)
+	if issue.Trace.Sink != nil {
+		steps = append(steps, issue.Trace.Sink.Origin())
+	}
+
+	locations := make([]sarifThreadFlowLocation, 0, len(steps))
+	for _, step := range steps {
+		file := step.File
+		if file == "" {
+			file = issue.File
+		}
+		location := newSarifLocation(file, step.Range, step.Range.Start.Line)
+		if step.Content != "" {
+			location.Message = &sarifMessage{Text: step.Content}
+		}
+		locations = append(locations, sarifThreadFlowLocation{Location: location})
+	}
+	return locations
+}
+
+func newSarifLocation(file string, fileRange tool.Range, line int) sarifLocation {
+	region := newSarifRegion(fileRange, line)
+	return sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: newSarifArtifactLocation(file), Region: &region}}
+}
+
+func newSarifArtifactLocation(file string) sarifArtifactLocation {
+	return sarifArtifactLocation{URI: (&url.URL{Path: filepath.ToSlash(file)}).String(), URIBaseID: sarifSourceRoot}
+}
+
+// newSarifRegion is the region of a range, or of the whole line when semgrep reported no range.
+func newSarifRegion(fileRange tool.Range, line int) sarifRegion {
+	if fileRange.Start.Line == 0 {
+		return sarifRegion{StartLine: line}
+	}
+	return sarifRegion{
+		StartLine:   fileRange.Start.Line,
+		StartColumn: fileRange.Start.Col,
+		EndLine:     fileRange.End.Line,
+		EndColumn:   fileRange.End.Col,
+	}
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/codacy/codacy-semgrep/internal/tool"
+	"github.com/stretchr/testify/assert"
+)
+
+const sarifRulesDefinition = `rules:
+  - id: python.sql-injection
+    languages: [python]
+    severity: ERROR
+    message: SQL injection
+    metadata:
+      category: security
+      cwe: "CWE-89: Improper Neutralization of Special Elements used in an SQL Command ('SQL Injection')"
+      owasp:
+        - A03:2021 - Injection
+    pattern: execute(...)
+`
+
+// writeSarifDocs writes a docs directory with the description of python.sql-injection.
+func writeSarifDocs(t *testing.T) string {
+	docsDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(docsDir, "description"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(docsDir, "rules.yaml"), []byte(sarifRulesDefinition), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(docsDir, "description", "description.json"),
+		[]byte(`[{"patternId": "python.sql-injection", "title": "SQL injection", "description": "Queries built from user input."}]`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(docsDir, "description", "python.sql-injection.md"),
+		[]byte("## SQL injection\nUse parameterized queries."), 0o644))
+	return docsDir
+}
+
+func TestWriteSarifResults(t *testing.T) {
+	// Arrange
+	docsDir := writeSarifDocs(t)
+	patterns := []codacy.Pattern{{ID: "python.sql-injection", Level: "Error", Category: "Security", SubCategory: "InputValidation"}}
+	issueRange := tool.Range{Start: tool.Position{Line: 9, Col: 5, Offset: 120}, End: tool.Position{Line: 9, Col: 20, Offset: 135}}
+	report := analysisReport{
+		toolDefinition: codacy.ToolDefinition{Name: "semgrep", Version: "1.78.0", Patterns: &patterns},
+		docsDir:        docsDir,
+		sourceDir:      "/src",
+		results: []codacy.Result{
+			tool.Issue{
+				Issue:       codacy.Issue{PatternID: "python.sql-injection", File: "app/db.py", Line: 9, Message: "SQL injection"},
+				Range:       issueRange,
+				Fix:         "execute(query, params)",
+				Fingerprint: "abc123",
+				Trace: &tool.DataflowTrace{
+					Source: &tool.CallTrace{Location: tool.TraceLocation{Range: tool.Range{Start: tool.Position{Line: 3, Col: 9}, End: tool.Position{Line: 3, Col: 21}}, Content: "request.args"}},
+					Sink:   &tool.CallTrace{Location: tool.TraceLocation{File: "app/db.py", Range: issueRange, Content: "execute(query)"}},
+				},
+			},
+			tool.Issue{Issue: codacy.Issue{PatternID: "org.custom", File: "app/main.py", Line: 2, Message: "Custom"}},
+			codacy.FileError{File: "app/big.py", Message: "Timeout"},
+		},
+	}
+	var output bytes.Buffer
+
+	// Act
+	err := writeSarifResults(&output, report)
+
+	// Assert
+	assert.NoError(t, err)
+	var log sarifLog
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	assert.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	assert.Equal(t, "semgrep", run.Tool.Driver.Name)
+	assert.Equal(t, "1.78.0", run.Tool.Driver.Version)
+	assert.Equal(t, "file:///src/", run.OriginalURIBaseIDs["SRCROOT"].URI)
+
+	assert.Equal(t, []sarifRule{
+		{ID: "org.custom", DefaultConfiguration: sarifRuleConfiguration{Level: "warning"}},
+		{
+			ID:                   "python.sql-injection",
+			ShortDescription:     &sarifMessage{Text: "SQL injection"},
+			FullDescription:      &sarifMessage{Text: "Queries built from user input."},
+			Help:                 &sarifHelp{Text: "## SQL injection\nUse parameterized queries.", Markdown: "## SQL injection\nUse parameterized queries."},
+			DefaultConfiguration: sarifRuleConfiguration{Level: "error"},
+			Properties: &sarifPropertiesWithTags{Tags: []string{
+				"security", "inputvalidation",
+				"CWE-89: Improper Neutralization of Special Elements used in an SQL Command ('SQL Injection')",
+				"A03:2021 - Injection",
+			}},
+		},
+	}, run.Tool.Driver.Rules)
+
+	assert.Len(t, run.Results, 2)
+	result := run.Results[0]
+	assert.Equal(t, "python.sql-injection", result.RuleID)
+	assert.Equal(t, 1, result.RuleIndex)
+	assert.Equal(t, "error", result.Level)
+	region := &sarifRegion{StartLine: 9, StartColumn: 5, EndLine: 9, EndColumn: 20}
+	assert.Equal(t, []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: "app/db.py", URIBaseID: "SRCROOT"},
+		Region:           region,
+	}}}, result.Locations)
+	assert.Equal(t, map[string]string{"codacySemgrepFingerprint/v1": "abc123"}, result.PartialFingerprints)
+	assert.Equal(t, []sarifReplacement{{DeletedRegion: *region, InsertedContent: sarifArtifactContent{Text: "execute(query, params)"}}},
+		result.Fixes[0].ArtifactChanges[0].Replacements)
+	flow := result.CodeFlows[0].ThreadFlows[0].Locations
+	assert.Len(t, flow, 2)
+	assert.Equal(t, "app/db.py", flow[0].Location.PhysicalLocation.ArtifactLocation.URI, "Expected the file of the issue for a step without a file")
+	assert.Equal(t, &sarifMessage{Text: "request.args"}, flow[0].Location.Message)
+
+	assert.Equal(t, 0, run.Results[1].RuleIndex)
+	assert.Equal(t, &sarifRegion{StartLine: 2}, run.Results[1].Locations[0].PhysicalLocation.Region, "Expected the line for an issue without a range")
+	assert.Nil(t, run.Results[1].PartialFingerprints)
+	assert.Nil(t, run.Results[1].Fixes)
+
+	notifications := run.Invocations[0].ToolExecutionNotifications
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, "Timeout", notifications[0].Message.Text)
+	assert.Equal(t, "app/big.py", notifications[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+}
+
+func TestWriteSarifResultsWithoutResults(t *testing.T) {
+	// Arrange
+	report := analysisReport{toolDefinition: codacy.ToolDefinition{Name: "semgrep"}, docsDir: t.TempDir(), sourceDir: "/src"}
+	var output bytes.Buffer
+
+	// Act
+	err := writeSarifResults(&output, report)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), `"results": []`)
+	assert.Contains(t, output.String(), `"rules": []`)
+}
+
+func TestSarifLevel(t *testing.T) {
+	assert.Equal(t, "error", sarifLevel("Error"))
+	assert.Equal(t, "warning", sarifLevel("Warning"))
+	assert.Equal(t, "note", sarifLevel("Info"))
+	assert.Equal(t, "warning", sarifLevel(""))
+}
@@ -0,0 +1,112 @@
+package tool
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// binaryDetectionBytes is how much of a file is read to tell if it is binary, like git does.
+const binaryDetectionBytes = 8000
+
+// agnosticRuleLanguages are the rule languages that don't depend on the language of the files,
+// with the language semgrep runs them with.
+var agnosticRuleLanguages = map[string]string{
+	"generic": "generic",
+	"regex":   "none",
+	"none":    "none",
+}
+
+// agnosticRuleIDPrefixes are the ids of the rules that can run on any text file, like the secrets rules.
+// Other generic rules, like the nginx ones, are written for the files of a language.
+var agnosticRuleIDPrefixes = []string{"generic.secrets.", "codacy.generic."}
+
+// languageRuleIDPrefixes are the ids of the rules with an agnostic prefix that are written for a language,
+// like codacy.generic.sql.grant-all. They only run on the files analysed as generic, like docgen documents them.
+var languageRuleIDPrefixes = []string{"codacy.generic.sql.", "codacy.generic.plsql.", "codacy.generic.tsql."}
+
+// agnosticRules returns the rules that can run on any text file: the generic and regex rules
+// with an agnostic id, like the secrets rules.
+func (r *ruleSet) agnosticRules() *ruleSet {
+	return &ruleSet{rules: lo.Filter(r.rules, func(rule *yaml.Node, _ int) bool {
+		return isAgnosticRule(rule)
+	})}
+}
+
+// agnosticLanguages returns the languages semgrep runs the rules with, sorted.
+func (r *ruleSet) agnosticLanguages() []string {
+	languages := lo.Uniq(lo.FlatMap(r.rules, func(rule *yaml.Node, _ int) []string {
+		return lo.Map(ruleLanguages(rule), func(language string, _ int) string {
+			return agnosticRuleLanguages[language]
+		})
+	}))
+	sort.Strings(languages)
+	return languages
+}
+
+func isAgnosticRule(rule *yaml.Node) bool {
+	languages := ruleLanguages(rule)
+	if len(languages) == 0 {
+		return false
+	}
+	for _, language := range languages {
+		if _, ok := agnosticRuleLanguages[language]; !ok {
+			return false
+		}
+	}
+
+	id := ruleID(rule)
+	hasPrefix := func(prefix string) bool { return strings.HasPrefix(id, prefix) }
+	return lo.SomeBy(agnosticRuleIDPrefixes, hasPrefix) && !lo.SomeBy(languageRuleIDPrefixes, hasPrefix)
+}
+
+// ruleLanguages returns the languages of a rule, in lower case.
+func ruleLanguages(rule *yaml.Node) []string {
+	languages := mappingValue(rule, "languages")
+	if languages == nil {
+		return nil
+	}
+	return lo.FilterMap(languages.Content, func(language *yaml.Node, _ int) (string, bool) {
+		return strings.ToLower(language.Value), language.Kind == yaml.ScalarNode
+	})
+}
+
+// newAgnosticConfigurationFile writes the agnostic rules of a configuration file to a new configuration file,
+// returning the languages to run them with. There is no file when the configuration has no agnostic rules.
+func newAgnosticConfigurationFile(configurationFileName string) (*os.File, []string, error) {
+	rules, err := readRuleFile(configurationFileName, configurationFileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	agnosticRules := rules.agnosticRules()
+	if len(agnosticRules.rules) == 0 {
+		return nil, nil, nil
+	}
+	configurationFile, err := writeConfigurationFile(agnosticRules)
+	if err != nil {
+		return nil, nil, err
+	}
+	return configurationFile, agnosticRules.agnosticLanguages(), nil
+}
+
+// isTextFile reports whether a file of the plan is a text file: it can be read and has no NUL byte at the start.
+func (p *analysisPlan) isTextFile(file string) bool {
+	f, err := os.Open(p.absolutePath(file))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	content := make([]byte, binaryDetectionBytes)
+	n, err := io.ReadFull(f, content)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false
+	}
+	return bytes.IndexByte(content[:n], 0) < 0
+}
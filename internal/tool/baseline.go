@@ -0,0 +1,175 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	baselineEnvironmentVariable = "CODACY_SEMGREP_BASELINE"
+	baselineVersion             = 1
+)
+
+// Baseline are the issues of a previous analysis, identified by their fingerprint.
+// Analyses with a baseline only report the issues that aren't in it.
+type Baseline struct {
+	Version int             `json:"version"`
+	Issues  []BaselineIssue `json:"issues"`
+}
+
+// BaselineIssue is an issue of a baseline. Only its fingerprint is used to match issues,
+// the rest helps reviewing the baseline.
+type BaselineIssue struct {
+	Fingerprint string `json:"fingerprint"`
+	PatternID   string `json:"patternId"`
+	File        string `json:"filename"`
+	Line        int    `json:"line"`
+}
+
+// BaselineSummary compares the issues of an analysis with a baseline.
+type BaselineSummary struct {
+	// New are the issues that aren't in the baseline
+	New int
+	// Existing are the issues that are in the baseline
+	Existing int
+	// Fixed are the issues of the baseline that the analysis didn't find
+	Fixed int
+}
+
+// NewBaseline creates the baseline of the issues of an analysis, sorted by file and line.
+func NewBaseline(results []codacy.Result) Baseline {
+	issues := lo.FilterMap(results, func(result codacy.Result, _ int) (BaselineIssue, bool) {
+		issue, ok := result.(Issue)
+		return BaselineIssue{
+			Fingerprint: issue.Fingerprint,
+			PatternID:   issue.PatternID,
+			File:        issue.File,
+			Line:        issue.Line,
+		}, ok
+	})
+	sort.Slice(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	return Baseline{Version: baselineVersion, Issues: issues}
+}
+
+// ReadBaseline reads a baseline file written by Baseline.Write.
+func ReadBaseline(fileName string) (Baseline, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return Baseline{}, fmt.Errorf("failed to read baseline file: %s\n%w", fileName, err)
+	}
+	baseline := Baseline{}
+	if err := json.Unmarshal(content, &baseline); err != nil {
+		return Baseline{}, fmt.Errorf("failed to parse baseline file: %s\n%w", fileName, err)
+	}
+	if baseline.Version != baselineVersion {
+		return Baseline{}, fmt.Errorf("unsupported baseline file version %d: %s", baseline.Version, fileName)
+	}
+	return baseline, nil
+}
+
+// Write writes the baseline to a file, replacing it if it exists.
+func (b Baseline) Write(fileName string) error {
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fileName, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write baseline file: %s\n%w", fileName, err)
+	}
+	return nil
+}
+
+// Filter removes the issues of the baseline from the results. Other results, like file errors, are kept.
+// Only the issues of the baseline in the analysed files can be fixed, like when only the changes of a pull request
+// are analysed. Nil analysed files are all the files of the baseline.
+func (b Baseline) Filter(results []codacy.Result, analysedFiles []string) ([]codacy.Result, BaselineSummary) {
+	fingerprints := b.fingerprints()
+	found := map[string]bool{}
+	summary := BaselineSummary{}
+
+	filtered := lo.Filter(results, func(result codacy.Result, _ int) bool {
+		issue, ok := result.(Issue)
+		if !ok {
+			return true
+		}
+		if fingerprints[issue.Fingerprint] {
+			found[issue.Fingerprint] = true
+			summary.Existing++
+			return false
+		}
+		summary.New++
+		return true
+	})
+
+	analysed := lo.SliceToMap(analysedFiles, func(file string) (string, bool) {
+		return normalizeBaselineFile(file), true
+	})
+	fixed := lo.Filter(b.Issues, func(issue BaselineIssue, _ int) bool {
+		return !found[issue.Fingerprint] && (analysedFiles == nil || analysed[normalizeBaselineFile(issue.File)])
+	})
+	summary.Fixed = len(lo.UniqBy(fixed, func(issue BaselineIssue) string { return issue.Fingerprint }))
+	return filtered, summary
+}
+
+// Compare compares the issues of the results of an analysis of all the files with the baseline.
+func (b Baseline) Compare(results []codacy.Result) BaselineSummary {
+	_, summary := b.Filter(results, nil)
+	return summary
+}
+
+func (b Baseline) fingerprints() map[string]bool {
+	return lo.SliceToMap(b.Issues, func(issue BaselineIssue) (string, bool) {
+		return issue.Fingerprint, true
+	})
+}
+
+// environmentBaselineFile resolves the baseline file with the environment of the process,
+// which takes precedence over the configured one. Relative files are in the source directory.
+func environmentBaselineFile(fileName, sourceDir string) string {
+	if value, ok := os.LookupEnv(baselineEnvironmentVariable); ok {
+		fileName = value
+	}
+	if fileName == "" || filepath.IsAbs(fileName) {
+		return fileName
+	}
+	return filepath.Join(sourceDir, fileName)
+}
+
+// normalizeBaselineFile is the path of a file the same way in the baseline and in the analysed files.
+func normalizeBaselineFile(file string) string {
+	return filepath.ToSlash(filepath.Clean(file))
+}
+
+// filterBaselineIssues removes the issues of the baseline file from the results of the analysed files,
+// and logs how they compare. Without baseline file, all the issues are new.
+func filterBaselineIssues(results []codacy.Result, baselineFile string, analysedFiles []string) ([]codacy.Result, error) {
+	if _, err := os.Stat(baselineFile); os.IsNotExist(err) {
+		logrus.Warnf("semgrep baseline %s doesn't exist, reporting all issues", baselineFile)
+		return results, nil
+	}
+	baseline, err := ReadBaseline(baselineFile)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered, summary := baseline.Filter(results, analysedFiles)
+	logrus.Infof("semgrep baseline %s: %d new issues, %d issues in the baseline, %d fixed issues",
+		baselineFile, summary.New, summary.Existing, summary.Fixed)
+	return filtered, nil
+}
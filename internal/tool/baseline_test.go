@@ -0,0 +1,157 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func baselineIssue(fingerprint, file string, line int) Issue {
+	return Issue{Issue: codacy.Issue{PatternID: "python.eval", File: file, Line: line}, Fingerprint: fingerprint}
+}
+
+func TestNewBaseline(t *testing.T) {
+	// Arrange
+	results := []codacy.Result{
+		baselineIssue("c", "b.py", 1),
+		codacy.FileError{File: "a.py", Message: "Syntax error: message"},
+		baselineIssue("b", "a.py", 7),
+		baselineIssue("a", "a.py", 2),
+	}
+
+	// Act
+	baseline := NewBaseline(results)
+
+	// Assert
+	assert.Equal(t, Baseline{Version: 1, Issues: []BaselineIssue{
+		{Fingerprint: "a", PatternID: "python.eval", File: "a.py", Line: 2},
+		{Fingerprint: "b", PatternID: "python.eval", File: "a.py", Line: 7},
+		{Fingerprint: "c", PatternID: "python.eval", File: "b.py", Line: 1},
+	}}, baseline)
+}
+
+func TestBaselineWriteAndRead(t *testing.T) {
+	// Arrange
+	baselineFile := filepath.Join(t.TempDir(), "baseline.json")
+	baseline := NewBaseline([]codacy.Result{baselineIssue("a", "a.py", 2)})
+
+	// Act
+	writeErr := baseline.Write(baselineFile)
+	readBaseline, readErr := ReadBaseline(baselineFile)
+
+	// Assert
+	assert.NoError(t, writeErr)
+	assert.NoError(t, readErr)
+	assert.Equal(t, baseline, readBaseline)
+}
+
+func TestReadBaselineWithInvalidFile(t *testing.T) {
+	testCases := map[string]string{
+		"invalid JSON":        "{",
+		"unsupported version": `{"version": 2, "issues": []}`,
+	}
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			baselineFile := filepath.Join(t.TempDir(), "baseline.json")
+			assert.NoError(t, os.WriteFile(baselineFile, []byte(content), 0o644))
+
+			_, err := ReadBaseline(baselineFile)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBaselineFilter(t *testing.T) {
+	// Arrange
+	baseline := NewBaseline([]codacy.Result{
+		baselineIssue("existing", "a.py", 2),
+		baselineIssue("fixed", "a.py", 5),
+	})
+	existing := baselineIssue("existing", "a.py", 12)
+	newIssue := baselineIssue("new", "a.py", 3)
+	fileError := codacy.FileError{File: "a.py", Message: "Syntax error: message"}
+
+	// Act
+	filtered, summary := baseline.Filter([]codacy.Result{existing, newIssue, fileError}, nil)
+
+	// Assert
+	assert.Equal(t, []codacy.Result{newIssue, fileError}, filtered)
+	assert.Equal(t, BaselineSummary{New: 1, Existing: 1, Fixed: 1}, summary)
+}
+
+func TestBaselineFilterWithDiffScope(t *testing.T) {
+	// Arrange
+	baseline := NewBaseline([]codacy.Result{
+		baselineIssue("existing", "app.py", 2),
+		baselineIssue("fixed", "app.py", 5),
+		baselineIssue("not-analysed", "lib/unchanged.py", 7),
+	})
+	plan := newAnalysisPlan("/src")
+	for _, file := range []string{"./app.py", "lib/unchanged.py"} {
+		plan.addFileToFilesByLanguage(file)
+	}
+	plan.keepChangedFiles(&sourceChanges{files: map[string]fileChanges{"app.py": {}}})
+
+	// Act
+	_, summary := baseline.Filter([]codacy.Result{baselineIssue("existing", "app.py", 2)}, plan.files)
+
+	// Assert
+	assert.Equal(t, BaselineSummary{New: 0, Existing: 1, Fixed: 1}, summary)
+}
+
+func TestEnvironmentBaselineFile(t *testing.T) {
+	testCases := []struct {
+		name             string
+		configured       string
+		environment      *string
+		expectedBaseline string
+	}{
+		{name: "no baseline", configured: "", expectedBaseline: ""},
+		{name: "relative file", configured: "baseline.json", expectedBaseline: "/src/baseline.json"},
+		{name: "absolute file", configured: "/baselines/semgrep.json", expectedBaseline: "/baselines/semgrep.json"},
+		{name: "environment", configured: "baseline.json", environment: lo.ToPtr("/baselines/semgrep.json"), expectedBaseline: "/baselines/semgrep.json"},
+		{name: "environment without baseline", configured: "baseline.json", environment: lo.ToPtr(""), expectedBaseline: ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if testCase.environment != nil {
+				t.Setenv(baselineEnvironmentVariable, *testCase.environment)
+			}
+
+			baselineFile := environmentBaselineFile(testCase.configured, "/src")
+
+			assert.Equal(t, testCase.expectedBaseline, baselineFile)
+		})
+	}
+}
+
+func TestFilterBaselineIssues(t *testing.T) {
+	// Arrange
+	baselineFile := filepath.Join(t.TempDir(), "baseline.json")
+	assert.NoError(t, NewBaseline([]codacy.Result{baselineIssue("existing", "a.py", 2)}).Write(baselineFile))
+	newIssue := baselineIssue("new", "a.py", 3)
+
+	// Act
+	filtered, err := filterBaselineIssues([]codacy.Result{baselineIssue("existing", "a.py", 2), newIssue}, baselineFile, []string{"a.py"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []codacy.Result{newIssue}, filtered)
+}
+
+func TestFilterBaselineIssuesWithoutBaselineFile(t *testing.T) {
+	// Arrange
+	results := []codacy.Result{baselineIssue("new", "a.py", 3)}
+
+	// Act
+	filtered, err := filterBaselineIssues(results, filepath.Join(t.TempDir(), "missing.json"), []string{"a.py"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, results, filtered)
+}
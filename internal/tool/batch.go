@@ -0,0 +1,79 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	// maxBatchFiles is the maximum number of files analysed by a single semgrep run.
+	maxBatchFiles = 1000
+	// maxBatchArgumentBytes bounds the length of the file names passed in the command line of a
+	// single semgrep run, far below the ARG_MAX of the platforms the tool runs on.
+	maxBatchArgumentBytes = 128 * 1024
+	// memoryPerSourceByte is a rough estimate of how many bytes of memory semgrep needs for each
+	// byte of source code it analyses. It is used to size batches to the memory of a process.
+	memoryPerSourceByte = 50
+)
+
+// maxBatchSourceBytes is how much source code a single semgrep run can analyse with the memory
+// that each of the processes running in parallel gets from the budget.
+func maxBatchSourceBytes(budget resourceBudget) int64 {
+	processMemoryMB := budget.memoryMB / budget.maxParallelism()
+	return int64(processMemoryMB) * 1024 * 1024 / memoryPerSourceByte
+}
+
+// batchFiles splits the files into batches that stay under the limits for the number of files,
+// the length of the command line and the total size of the source code.
+// A file bigger than maxSourceBytes gets a batch of its own. The order of the files is kept.
+func batchFiles(files []string, sizeOf func(string) int64, maxSourceBytes int64) [][]string {
+	var batches [][]string
+	var batch []string
+	var batchArgumentBytes int
+	var batchSourceBytes int64
+
+	for _, file := range files {
+		argumentBytes := len(file) + 1
+		sourceBytes := sizeOf(file)
+
+		if len(batch) > 0 && (len(batch) >= maxBatchFiles ||
+			batchArgumentBytes+argumentBytes > maxBatchArgumentBytes ||
+			batchSourceBytes+sourceBytes > maxSourceBytes) {
+			batches = append(batches, batch)
+			batch = nil
+			batchArgumentBytes = 0
+			batchSourceBytes = 0
+		}
+
+		batch = append(batch, file)
+		batchArgumentBytes += argumentBytes
+		batchSourceBytes += sourceBytes
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// fileSize returns the size of a file of the plan, or 0 when it can't be read.
+// Unreadable files are left for semgrep to report.
+func (p *analysisPlan) fileSize(file string) int64 {
+	fileInfo, err := os.Stat(p.absolutePath(file))
+	if err != nil {
+		return 0
+	}
+	return fileInfo.Size()
+}
+
+// absolutePath resolves a file of the plan, which can be relative to the source directory.
+func (p *analysisPlan) absolutePath(file string) string {
+	return sourcePath(p.sourceDir, file)
+}
+
+// sourcePath resolves a file that can be relative to the source directory.
+func sourcePath(sourceDir, file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(sourceDir, file)
+}
@@ -0,0 +1,94 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchFilesByCount(t *testing.T) {
+	// Arrange
+	files := make([]string, maxBatchFiles*2+1)
+	for i := range files {
+		files[i] = fmt.Sprintf("file%d.go", i)
+	}
+
+	// Act
+	batches := batchFiles(files, func(string) int64 { return 1 }, 1<<30)
+
+	// Assert
+	assert.Len(t, batches, 3)
+	assert.Len(t, batches[0], maxBatchFiles)
+	assert.Len(t, batches[1], maxBatchFiles)
+	assert.Equal(t, []string{files[len(files)-1]}, batches[2])
+}
+
+func TestBatchFilesByArgumentBytes(t *testing.T) {
+	// Arrange
+	longName := strings.Repeat("a", 1023) + ".go"
+	files := make([]string, 200)
+	for i := range files {
+		files[i] = longName
+	}
+
+	// Act
+	batches := batchFiles(files, func(string) int64 { return 1 }, 1<<30)
+
+	// Assert
+	assert.Len(t, batches, 2)
+	for _, batch := range batches {
+		argumentBytes := 0
+		for _, file := range batch {
+			argumentBytes += len(file) + 1
+		}
+		assert.LessOrEqual(t, argumentBytes, maxBatchArgumentBytes)
+	}
+}
+
+func TestBatchFilesBySourceBytes(t *testing.T) {
+	// Arrange
+	sizes := map[string]int64{"a.go": 40, "b.go": 40, "huge.go": 500, "c.go": 10}
+	files := []string{"a.go", "b.go", "huge.go", "c.go"}
+
+	// Act
+	batches := batchFiles(files, func(file string) int64 { return sizes[file] }, 100)
+
+	// Assert
+	assert.Equal(t, [][]string{{"a.go", "b.go"}, {"huge.go"}, {"c.go"}}, batches)
+}
+
+func TestBatchFilesWithoutFiles(t *testing.T) {
+	// Act
+	batches := batchFiles(nil, func(string) int64 { return 1 }, 100)
+
+	// Assert
+	assert.Empty(t, batches)
+}
+
+func TestMaxBatchSourceBytesSplitsMemoryBetweenProcesses(t *testing.T) {
+	// Arrange
+	budget := resourceBudget{cpus: 4, memoryMB: 4000}
+
+	// Act
+	maxSourceBytes := maxBatchSourceBytes(budget)
+
+	// Assert
+	assert.Equal(t, int64(1000*1024*1024/memoryPerSourceByte), maxSourceBytes)
+}
+
+func TestAnalysisPlanFileSize(t *testing.T) {
+	// Arrange
+	sourceDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte("package main\n"), 0600)
+	assert.NoError(t, err)
+	plan := newAnalysisPlan(sourceDir)
+
+	// Act & Assert
+	assert.Equal(t, int64(13), plan.fileSize("main.go"))
+	assert.Equal(t, int64(13), plan.fileSize(filepath.Join(sourceDir, "main.go")))
+	assert.Equal(t, int64(0), plan.fileSize("missing.go"))
+}
@@ -0,0 +1,405 @@
+package tool
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	cacheDirEnvironmentVariable     = "CODACY_SEMGREP_CACHE_DIR"
+	cacheMaxSizeEnvironmentVariable = "CODACY_SEMGREP_CACHE_MAX_SIZE_MB"
+	defaultCacheMaxSizeMB           = 512
+	// cacheEntryVersion changes when the format of the cache entries changes, so older entries are discarded
+	cacheEntryVersion = 1
+)
+
+// CacheSettings configure the cache of the results of semgrep for each file,
+// so files that didn't change since a previous analysis aren't analysed again.
+// Without Dir, there is no cache.
+type CacheSettings struct {
+	// Dir is the directory of the cache, created when it doesn't exist.
+	Dir string
+	// MaxSizeMB is the size, in megabytes, above which the least recently used results are removed.
+	MaxSizeMB int
+}
+
+// environmentCacheSettings resolves the cache settings with the environment of the process,
+// which takes precedence over the configured ones.
+func environmentCacheSettings(settings CacheSettings) (CacheSettings, error) {
+	if value, ok := os.LookupEnv(cacheDirEnvironmentVariable); ok {
+		settings.Dir = strings.TrimSpace(value)
+	}
+	if value, ok := os.LookupEnv(cacheMaxSizeEnvironmentVariable); ok {
+		maxSizeMB, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return settings, fmt.Errorf("invalid semgrep cache size %s: %w", cacheMaxSizeEnvironmentVariable, err)
+		}
+		settings.MaxSizeMB = maxSizeMB
+	}
+	if settings.MaxSizeMB == 0 {
+		settings.MaxSizeMB = defaultCacheMaxSizeMB
+	}
+	if settings.MaxSizeMB < 0 {
+		return settings, fmt.Errorf("invalid semgrep cache size %d: must be positive", settings.MaxSizeMB)
+	}
+	return settings, nil
+}
+
+// resultCache stores the results of semgrep for a file analysed with a language and a configuration,
+// in a file per result named after its key.
+type resultCache struct {
+	dir          string
+	maxSizeBytes int64
+	// toolVersion is the version of semgrep, part of every key
+	toolVersion string
+}
+
+// cacheEntry is the content of a file of the cache. The checksum of the results detects corrupted entries.
+type cacheEntry struct {
+	Version  int             `json:"version"`
+	Key      string          `json:"key"`
+	Checksum string          `json:"checksum"`
+	Results  json.RawMessage `json:"results"`
+}
+
+type cachedResults struct {
+	Issues     []Issue            `json:"issues"`
+	FileErrors []codacy.FileError `json:"fileErrors"`
+}
+
+// newResultCache opens the cache of the settings, or returns nil when there is no cache.
+func newResultCache(settings CacheSettings, toolVersion string) (*resultCache, error) {
+	if settings.Dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(settings.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create semgrep cache directory: %s\n%w", settings.Dir, err)
+	}
+	return &resultCache{
+		dir:          settings.Dir,
+		maxSizeBytes: int64(settings.MaxSizeMB) * 1024 * 1024,
+		toolVersion:  toolVersion,
+	}, nil
+}
+
+// key identifies the results of a file, from its name and content hash,
+// analysed with a language, the configuration with a hash and the limits that change the results.
+func (c *resultCache) key(configurationHash, language string, limits EngineLimits, file, contentHash string) string {
+	return hashStrings(c.toolVersion, configurationHash, language, limits.String(), file, contentHash)
+}
+
+// get returns the results of a key. Entries that can't be read or don't match their key and checksum are removed.
+func (c *resultCache) get(key string) ([]codacy.Result, bool) {
+	entryPath := c.entryPath(key)
+	content, err := os.ReadFile(entryPath)
+	if err != nil {
+		return nil, false
+	}
+
+	results, err := decodeCacheEntry(content, key)
+	if err != nil {
+		logrus.Warnf("semgrep cache entry %s is discarded: %s", entryPath, err.Error())
+		os.Remove(entryPath)
+		return nil, false
+	}
+	// The modification time tells which entries were used least recently
+	now := time.Now()
+	os.Chtimes(entryPath, now, now)
+	return results, true
+}
+
+func decodeCacheEntry(content []byte, key string) ([]codacy.Result, error) {
+	entry := cacheEntry{}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, err
+	}
+	if entry.Version != cacheEntryVersion {
+		return nil, fmt.Errorf("unsupported version %d", entry.Version)
+	}
+	if entry.Key != key {
+		return nil, fmt.Errorf("key %s doesn't match", entry.Key)
+	}
+	if hashBytes(entry.Results) != entry.Checksum {
+		return nil, fmt.Errorf("checksum doesn't match")
+	}
+
+	cached := cachedResults{}
+	decoder := json.NewDecoder(bytes.NewReader(entry.Results))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cached); err != nil {
+		return nil, err
+	}
+	results := make([]codacy.Result, 0, len(cached.Issues)+len(cached.FileErrors))
+	for _, issue := range cached.Issues {
+		results = append(results, issue)
+	}
+	for _, fileError := range cached.FileErrors {
+		results = append(results, fileError)
+	}
+	return results, nil
+}
+
+// put stores the results of a key. The entry is written to a temporary file first,
+// so an interrupted write never leaves a partial entry.
+func (c *resultCache) put(key string, results []codacy.Result) error {
+	cached := cachedResults{Issues: []Issue{}, FileErrors: []codacy.FileError{}}
+	for _, result := range results {
+		switch result := result.(type) {
+		case Issue:
+			cached.Issues = append(cached.Issues, result)
+		case codacy.FileError:
+			cached.FileErrors = append(cached.FileErrors, result)
+		}
+	}
+	encodedResults, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(cacheEntry{
+		Version:  cacheEntryVersion,
+		Key:      key,
+		Checksum: hashBytes(encodedResults),
+		Results:  encodedResults,
+	})
+	if err != nil {
+		return err
+	}
+
+	entryPath := c.entryPath(key)
+	if err := os.MkdirAll(filepath.Dir(entryPath), 0o755); err != nil {
+		return err
+	}
+	temporaryFile, err := os.CreateTemp(filepath.Dir(entryPath), "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporaryFile.Name())
+	if _, err := temporaryFile.Write(content); err != nil {
+		temporaryFile.Close()
+		return err
+	}
+	if err := temporaryFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporaryFile.Name(), entryPath)
+}
+
+// entryPath spreads the entries in subdirectories by the first characters of their key.
+func (c *resultCache) entryPath(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// evict removes the least recently used entries until the cache is below its maximum size.
+func (c *resultCache) evict() error {
+	type entryFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entryFile
+	var size int64
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, entryFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if size <= c.maxSizeBytes {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+	removed := 0
+	for _, entry := range entries {
+		if size <= c.maxSizeBytes {
+			break
+		}
+		if err := os.Remove(entry.path); err != nil {
+			continue
+		}
+		size -= entry.size
+		removed++
+	}
+	logrus.Infof("semgrep cache removed %d least recently used results", removed)
+	return nil
+}
+
+// cacheableResults reports whether the results of a file would be the same on another analysis.
+// Timeouts and memory limits depend on the load of the machine, and internal errors can be transient.
+func cacheableResults(results []codacy.Result) bool {
+	return !lo.ContainsBy(results, func(result codacy.Result) bool {
+		fileError, ok := result.(codacy.FileError)
+		return ok && !strings.HasPrefix(fileError.Message, string(syntaxError)+": ") &&
+			!strings.HasPrefix(fileError.Message, string(unsupportedLanguage)+": ")
+	})
+}
+
+func hashFile(fileName string) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashBytes(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// hashStrings hashes the values, separated so that moving characters between them changes the hash.
+func hashStrings(values ...string) string {
+	return hashBytes([]byte(strings.Join(values, "\x00")))
+}
+
+// cacheTarget is a file analysed with a language, with the rules of the plan or with its agnostic rules.
+type cacheTarget struct {
+	language string
+	file     string
+	agnostic bool
+}
+
+// targets returns what the plan analyses, in the order of its jobs.
+func (p *analysisPlan) targets() []cacheTarget {
+	filesByLanguage := p.filesByLanguage()
+	languages := lo.Keys(filesByLanguage)
+	sort.Strings(languages)
+
+	var targets []cacheTarget
+	for _, language := range languages {
+		for _, file := range filesByLanguage[language] {
+			targets = append(targets, cacheTarget{language: language, file: file})
+		}
+	}
+	textFiles := p.textFiles()
+	for _, language := range p.agnosticLanguages {
+		for _, file := range textFiles {
+			targets = append(targets, cacheTarget{language: language, file: file, agnostic: true})
+		}
+	}
+	return targets
+}
+
+// hashFiles hashes the files of the targets, before the jobs run. Files that can't be read have no hash.
+func (p *analysisPlan) hashFiles(targets []cacheTarget) {
+	for _, target := range targets {
+		if _, hashed := p.fileHashes[target.file]; !hashed {
+			p.fileHashes[target.file], _ = hashFile(p.absolutePath(target.file))
+		}
+	}
+}
+
+// cacheKey returns the key of the results of a target, which has none when its file has no hash.
+// Jobs call it concurrently, so it only reads the hashes computed by hashFiles.
+func (p *analysisPlan) cacheKey(target cacheTarget) (string, bool) {
+	contentHash := p.fileHashes[target.file]
+	if contentHash == "" {
+		return "", false
+	}
+	configurationHash := p.configurationHash
+	if target.agnostic {
+		configurationHash = p.agnosticConfigurationHash
+	}
+	limits := p.engineLimits.resultLimits(target.language)
+	return p.cache.key(configurationHash, target.language, limits, target.file, contentHash), true
+}
+
+// loadCachedResults returns the cached results of the targets of the plan.
+// The targets with cached results are left out of the jobs of the plan.
+func (p *analysisPlan) loadCachedResults() []codacy.Result {
+	if p.cache == nil {
+		return nil
+	}
+
+	var results []codacy.Result
+	targets := p.targets()
+	p.hashFiles(targets)
+	for _, target := range targets {
+		key, ok := p.cacheKey(target)
+		if !ok {
+			continue
+		}
+		if cached, found := p.cache.get(key); found {
+			results = append(results, cached...)
+			p.cachedTargets[target] = true
+		}
+	}
+	logrus.Infof("semgrep results of %d of %d files and languages are cached", len(p.cachedTargets), len(targets))
+	return results
+}
+
+// storeCachedResults caches the results of a job for each of its files, including the files without results.
+// Nothing is cached when a result isn't about one of the files of the job.
+// Jobs run concurrently: they only read the hashes of the files, all computed by loadCachedResults.
+// The results of a file that wasn't hashed aren't cached.
+func (p *analysisPlan) storeCachedResults(job semgrepJob, results []codacy.Result) {
+	if p.cache == nil {
+		return
+	}
+
+	resultsByFile := lo.SliceToMap(job.files, func(file string) (string, []codacy.Result) {
+		return file, []codacy.Result{}
+	})
+	for _, result := range results {
+		file := result.GetFile()
+		if _, inJob := resultsByFile[file]; !inJob {
+			logrus.Debugf("semgrep results of %s aren't cached: unexpected result for %s", job.language, file)
+			return
+		}
+		resultsByFile[file] = append(resultsByFile[file], result)
+	}
+
+	for file, fileResults := range resultsByFile {
+		if !cacheableResults(fileResults) {
+			continue
+		}
+		key, ok := p.cacheKey(cacheTarget{language: job.language, file: file, agnostic: job.agnostic})
+		if !ok {
+			continue
+		}
+		if err := p.cache.put(key, fileResults); err != nil {
+			logrus.Warnf("semgrep results of %s aren't cached: %s", file, err.Error())
+		}
+	}
+}
+
+// uncachedFiles returns the files of a language that have no cached results.
+func (p *analysisPlan) uncachedFiles(language string, agnostic bool, files []string) []string {
+	if len(p.cachedTargets) == 0 {
+		return files
+	}
+	return lo.Filter(files, func(file string, _ int) bool {
+		return !p.cachedTargets[cacheTarget{language: language, file: file, agnostic: agnostic}]
+	})
+}
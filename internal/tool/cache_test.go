@@ -0,0 +1,295 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestResultCache(t *testing.T) *resultCache {
+	cache, err := newResultCache(CacheSettings{Dir: t.TempDir(), MaxSizeMB: 1}, "1.78.0")
+	assert.NoError(t, err)
+	return cache
+}
+
+func TestResultCachePutAndGet(t *testing.T) {
+	// Arrange
+	cache := newTestResultCache(t)
+	key := cache.key("configuration", "python", EngineLimits{}, "app.py", "content")
+	issue := Issue{
+		Issue: codacy.Issue{PatternID: "python.sqli", File: "app.py", Line: 9, Message: "SQL injection."},
+		Range: Range{Start: Position{Line: 9, Col: 1, Offset: 100}, End: Position{Line: 9, Col: 22, Offset: 121}},
+		Fix:   "cursor.execute(query, params)",
+		Trace: &DataflowTrace{Source: &CallTrace{Location: TraceLocation{File: "app.py", Content: "request.args"}}},
+	}
+	fileError := codacy.FileError{File: "app.py", Message: "Syntax error: message"}
+
+	// Act
+	err := cache.put(key, []codacy.Result{issue, fileError})
+	results, found := cache.get(key)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []codacy.Result{issue, fileError}, results)
+}
+
+func TestResultCacheGetWithoutEntry(t *testing.T) {
+	// Arrange
+	cache := newTestResultCache(t)
+
+	// Act
+	results, found := cache.get(cache.key("configuration", "python", EngineLimits{}, "app.py", "content"))
+
+	// Assert
+	assert.False(t, found)
+	assert.Nil(t, results)
+}
+
+func TestResultCacheKey(t *testing.T) {
+	cache := newTestResultCache(t)
+	key := cache.key("configuration", "python", EngineLimits{}, "app.py", "content")
+
+	assert.NotEqual(t, key, cache.key("other configuration", "python", EngineLimits{}, "app.py", "content"))
+	assert.NotEqual(t, key, cache.key("configuration", "generic", EngineLimits{}, "app.py", "content"))
+	assert.NotEqual(t, key, cache.key("configuration", "python", EngineLimits{}, "main.py", "content"))
+	assert.NotEqual(t, key, cache.key("configuration", "python", EngineLimits{}, "app.py", "other content"))
+	assert.NotEqual(t, key, cache.key("configuration", "python", EngineLimits{MaxTargetBytes: 1000}, "app.py", "content"))
+	otherVersion := &resultCache{toolVersion: "1.79.0"}
+	assert.NotEqual(t, key, otherVersion.key("configuration", "python", EngineLimits{}, "app.py", "content"))
+}
+
+func TestResultCacheDiscardsCorruptedEntries(t *testing.T) {
+	testCases := map[string]func(content []byte) []byte{
+		"truncated entry": func(content []byte) []byte {
+			return content[:len(content)/2]
+		},
+		"changed results": func(content []byte) []byte {
+			return []byte(string(content[:len(content)-20]) + `,"line":3}]}}`)
+		},
+		"invalid JSON": func([]byte) []byte {
+			return []byte("\x00\x01garbage")
+		},
+		"unsupported version": func([]byte) []byte {
+			return []byte(`{"version": 99}`)
+		},
+	}
+	for name, corrupt := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			cache := newTestResultCache(t)
+			key := cache.key("configuration", "python", EngineLimits{}, "app.py", "content")
+			assert.NoError(t, cache.put(key, []codacy.Result{Issue{Issue: codacy.Issue{PatternID: "rule", File: "app.py", Line: 1}}}))
+			content, err := os.ReadFile(cache.entryPath(key))
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(cache.entryPath(key), corrupt(content), 0o644))
+
+			// Act
+			results, found := cache.get(key)
+
+			// Assert
+			assert.False(t, found)
+			assert.Nil(t, results)
+			assert.NoFileExists(t, cache.entryPath(key))
+		})
+	}
+}
+
+func TestResultCacheDiscardsEntriesOfAnotherKey(t *testing.T) {
+	// Arrange
+	cache := newTestResultCache(t)
+	key := cache.key("configuration", "python", EngineLimits{}, "app.py", "content")
+	otherKey := cache.key("configuration", "python", EngineLimits{}, "main.py", "content")
+	assert.NoError(t, cache.put(otherKey, []codacy.Result{}))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(cache.entryPath(key)), 0o755))
+	assert.NoError(t, os.Rename(cache.entryPath(otherKey), cache.entryPath(key)))
+
+	// Act
+	_, found := cache.get(key)
+
+	// Assert
+	assert.False(t, found)
+	assert.NoFileExists(t, cache.entryPath(key))
+}
+
+func TestResultCacheEvict(t *testing.T) {
+	// Arrange
+	cache := newTestResultCache(t)
+	message := strings.Repeat("x", 300)
+	var keys []string
+	for i, file := range []string{"a.py", "b.py", "c.py", "d.py"} {
+		key := cache.key("configuration", "python", EngineLimits{}, file, "content")
+		assert.NoError(t, cache.put(key, []codacy.Result{codacy.FileError{File: file, Message: message}}))
+		usedAt := time.Now().Add(time.Duration(i-10) * time.Hour)
+		assert.NoError(t, os.Chtimes(cache.entryPath(key), usedAt, usedAt))
+		keys = append(keys, key)
+	}
+	entryInfo, err := os.Stat(cache.entryPath(keys[0]))
+	assert.NoError(t, err)
+	cache.maxSizeBytes = 2 * entryInfo.Size()
+	// Using an entry makes it the most recently used
+	_, found := cache.get(keys[0])
+	assert.True(t, found)
+
+	// Act
+	err = cache.evict()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.FileExists(t, cache.entryPath(keys[0]))
+	assert.NoFileExists(t, cache.entryPath(keys[1]))
+	assert.NoFileExists(t, cache.entryPath(keys[2]))
+	assert.FileExists(t, cache.entryPath(keys[3]))
+}
+
+func TestCacheableResults(t *testing.T) {
+	issue := Issue{Issue: codacy.Issue{PatternID: "rule", File: "app.py", Line: 1}}
+
+	assert.True(t, cacheableResults([]codacy.Result{}))
+	assert.True(t, cacheableResults([]codacy.Result{issue, codacy.FileError{File: "app.py", Message: "Syntax error: message"}}))
+	assert.True(t, cacheableResults([]codacy.Result{codacy.FileError{File: "app.py", Message: "Unsupported language: message"}}))
+	assert.False(t, cacheableResults([]codacy.Result{issue, codacy.FileError{File: "app.py", Message: "Rule timeout: message"}}))
+	assert.False(t, cacheableResults([]codacy.Result{codacy.FileError{File: "app.py", Message: "Memory limit exceeded: message"}}))
+	assert.False(t, cacheableResults([]codacy.Result{codacy.FileError{File: "app.py", Message: "Internal error: message"}}))
+}
+
+func TestEnvironmentCacheSettings(t *testing.T) {
+	testCases := []struct {
+		name             string
+		configured       CacheSettings
+		environment      map[string]string
+		expectedSettings CacheSettings
+		expectedError    string
+	}{
+		{
+			name:             "defaults",
+			expectedSettings: CacheSettings{MaxSizeMB: defaultCacheMaxSizeMB},
+		},
+		{
+			name:             "configured",
+			configured:       CacheSettings{Dir: "/cache", MaxSizeMB: 100},
+			expectedSettings: CacheSettings{Dir: "/cache", MaxSizeMB: 100},
+		},
+		{
+			name:             "environment",
+			configured:       CacheSettings{Dir: "/cache", MaxSizeMB: 100},
+			environment:      map[string]string{cacheDirEnvironmentVariable: "/tmp/cache", cacheMaxSizeEnvironmentVariable: "20"},
+			expectedSettings: CacheSettings{Dir: "/tmp/cache", MaxSizeMB: 20},
+		},
+		{
+			name:          "invalid size",
+			environment:   map[string]string{cacheMaxSizeEnvironmentVariable: "big"},
+			expectedError: "invalid semgrep cache size CODACY_SEMGREP_CACHE_MAX_SIZE_MB",
+		},
+		{
+			name:          "negative size",
+			configured:    CacheSettings{MaxSizeMB: -1},
+			expectedError: "invalid semgrep cache size -1",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			for variable, value := range testCase.environment {
+				t.Setenv(variable, value)
+			}
+
+			settings, err := environmentCacheSettings(testCase.configured)
+
+			if testCase.expectedError != "" {
+				assert.ErrorContains(t, err, testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedSettings, settings)
+		})
+	}
+}
+
+func TestNewResultCacheWithoutDir(t *testing.T) {
+	cache, err := newResultCache(CacheSettings{MaxSizeMB: 1}, "1.78.0")
+
+	assert.NoError(t, err)
+	assert.Nil(t, cache)
+}
+
+// newCachedAnalysisPlan returns a plan for the files of the source directory that uses the cache.
+func newCachedAnalysisPlan(t *testing.T, sourceDir string, cache *resultCache, files []string) *analysisPlan {
+	plan := newAnalysisPlan(sourceDir)
+	configurationFile, err := os.CreateTemp(t.TempDir(), "semgrep-*.yaml")
+	assert.NoError(t, err)
+	configurationFile.Close()
+	plan.configurationFile = configurationFile
+	assert.NoError(t, plan.populateFilesByLanguage(&files, sourceDir))
+	assert.NoError(t, plan.useCache(cache))
+	return plan
+}
+
+func TestAnalysisPlanSkipsCachedFiles(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{"app.py": "eval(x)\n", "lib.py": "y = 1\n", "main.go": "package main\n"})
+	cache := newTestResultCache(t)
+	files := []string{"app.py", "lib.py", "main.go"}
+	issue := Issue{Issue: codacy.Issue{PatternID: "python.eval", File: "app.py", Line: 1}}
+	firstPlan := newCachedAnalysisPlan(t, sourceDir, cache, files)
+	assert.Empty(t, firstPlan.loadCachedResults())
+	firstPlan.storeCachedResults(semgrepJob{language: "python", files: []string{"app.py", "lib.py"}}, []codacy.Result{issue})
+	assert.NoError(t, os.WriteFile(filepath.Join(sourceDir, "lib.py"), []byte("y = 2\n"), 0o644))
+
+	// Act
+	plan := newCachedAnalysisPlan(t, sourceDir, cache, files)
+	cachedResults := plan.loadCachedResults()
+	jobs := plan.jobs(DefaultEngineLimits().budget())
+
+	// Assert
+	assert.Equal(t, []codacy.Result{issue}, cachedResults)
+	assert.Equal(t, []semgrepJob{
+		{language: "go", files: []string{"main.go"}},
+		{language: "python", files: []string{"lib.py"}},
+	}, jobs)
+}
+
+func TestAnalysisPlanDoesNotUseResultsOfOtherLimits(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{"app.py": "eval(x)\n"})
+	cache := newTestResultCache(t)
+	files := []string{"app.py"}
+	firstPlan := newCachedAnalysisPlan(t, sourceDir, cache, files)
+	firstPlan.engineLimits = engineLimitsConfiguration{global: EngineLimits{MaxTargetBytes: 4}}
+	firstPlan.loadCachedResults()
+	// The file is above the size limit, so semgrep reports nothing
+	firstPlan.storeCachedResults(semgrepJob{language: "python", files: files}, nil)
+
+	// Act
+	plan := newCachedAnalysisPlan(t, sourceDir, cache, files)
+	plan.engineLimits = engineLimitsConfiguration{global: EngineLimits{MaxTargetBytes: 0}, languages: map[string]EngineLimits{}}
+	plan.loadCachedResults()
+	sameLimitsPlan := newCachedAnalysisPlan(t, sourceDir, cache, files)
+	sameLimitsPlan.engineLimits = engineLimitsConfiguration{global: EngineLimits{MaxTargetBytes: 4, Jobs: 8, MaxMemoryMB: 8000}}
+	sameLimitsPlan.loadCachedResults()
+
+	// Assert
+	assert.Len(t, plan.jobs(DefaultEngineLimits().budget()), 1, "Expected the file to be analysed again with another size limit")
+	assert.Empty(t, sameLimitsPlan.jobs(DefaultEngineLimits().budget()), "Expected resources not to change the key")
+}
+
+func TestStoreCachedResultsWithUnexpectedFile(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{"app.py": "eval(x)\n"})
+	cache := newTestResultCache(t)
+	files := []string{"app.py"}
+	plan := newCachedAnalysisPlan(t, sourceDir, cache, files)
+	plan.loadCachedResults()
+	unexpected := Issue{Issue: codacy.Issue{PatternID: "python.eval", File: "./app.py", Line: 1}}
+
+	// Act
+	plan.storeCachedResults(semgrepJob{language: "python", files: files}, []codacy.Result{unexpected})
+
+	// Assert
+	assert.Empty(t, newCachedAnalysisPlan(t, sourceDir, cache, files).loadCachedResults())
+	assert.Len(t, newCachedAnalysisPlan(t, sourceDir, cache, files).jobs(DefaultEngineLimits().budget()), 1)
+}
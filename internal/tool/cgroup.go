@@ -0,0 +1,160 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// usableMemoryPercentage is the part of the available memory semgrep is allowed to use,
+	// leaving the rest for the tool itself and the rest of the container.
+	usableMemoryPercentage = 80
+	// fallbackMemoryMB is the memory assumed to be available when it can't be detected.
+	fallbackMemoryMB = 5000 * 100 / usableMemoryPercentage
+)
+
+// detectedResources are the CPUs and memory available to the process, detected once.
+var detectedResources = sync.OnceValue(func() resourceBudget {
+	return detectResources(os.DirFS("/"), runtime.NumCPU())
+})
+
+// detectResources returns the CPUs and memory available to the process.
+// The limits of the cgroup (v2 or v1) the process runs in are used when they exist,
+// otherwise the host CPUs and memory.
+// Paths are relative to the root of fsys, so tests can provide their own files.
+func detectResources(fsys fs.FS, hostCPUs int) resourceBudget {
+	cpus := hostCPUs
+	if cgroupCPUs, ok := cgroupCPULimit(fsys); ok {
+		cpus = max(1, min(cpus, cgroupCPUs))
+	}
+
+	memoryMB, ok := hostMemoryMB(fsys)
+	if !ok {
+		memoryMB = fallbackMemoryMB
+	}
+	if cgroupMemoryMB, ok := cgroupMemoryLimitMB(fsys); ok {
+		memoryMB = min(memoryMB, cgroupMemoryMB)
+	}
+
+	return resourceBudget{cpus: cpus, memoryMB: memoryMB}
+}
+
+// usableMemoryMB is the memory semgrep processes can use out of the memory available.
+func (b resourceBudget) usableMemoryMB() int {
+	return b.memoryMB * usableMemoryPercentage / 100
+}
+
+// cgroupV2Dirs are the directories where the cgroup v2 files of the process can be found:
+// its own cgroup, when the cgroup namespace isn't private, and the root of the hierarchy.
+func cgroupV2Dirs(fsys fs.FS) []string {
+	dirs := []string{}
+	if content, err := fs.ReadFile(fsys, "proc/self/cgroup"); err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			if cgroupPath, ok := strings.CutPrefix(scanner.Text(), "0::"); ok && cgroupPath != "/" {
+				dirs = append(dirs, path.Join("sys/fs/cgroup", cgroupPath))
+			}
+		}
+	}
+	return append(dirs, "sys/fs/cgroup")
+}
+
+func cgroupCPULimit(fsys fs.FS) (int, bool) {
+	// cgroup v2: "<quota> <period>" or "max <period>"
+	for _, dir := range cgroupV2Dirs(fsys) {
+		fields, ok := readFields(fsys, path.Join(dir, "cpu.max"))
+		if !ok || len(fields) != 2 {
+			continue
+		}
+		if fields[0] == "max" {
+			return 0, false
+		}
+		return cpusFromQuota(fields[0], fields[1])
+	}
+
+	// cgroup v1: quota is -1 when there is no limit
+	for _, dir := range []string{"sys/fs/cgroup/cpu", "sys/fs/cgroup/cpu,cpuacct"} {
+		quota, quotaOk := readFields(fsys, path.Join(dir, "cpu.cfs_quota_us"))
+		period, periodOk := readFields(fsys, path.Join(dir, "cpu.cfs_period_us"))
+		if quotaOk && periodOk && len(quota) == 1 && len(period) == 1 {
+			return cpusFromQuota(quota[0], period[0])
+		}
+	}
+	return 0, false
+}
+
+func cpusFromQuota(quotaField, periodField string) (int, bool) {
+	quota, err := strconv.ParseFloat(quotaField, 64)
+	if err != nil || quota <= 0 {
+		return 0, false
+	}
+	period, err := strconv.ParseFloat(periodField, 64)
+	if err != nil || period <= 0 {
+		return 0, false
+	}
+	return int(math.Ceil(quota / period)), true
+}
+
+func cgroupMemoryLimitMB(fsys fs.FS) (int, bool) {
+	// cgroup v2: "max" when there is no limit
+	for _, dir := range cgroupV2Dirs(fsys) {
+		fields, ok := readFields(fsys, path.Join(dir, "memory.max"))
+		if !ok || len(fields) != 1 {
+			continue
+		}
+		if fields[0] == "max" {
+			return 0, false
+		}
+		return megabytesFromBytes(fields[0])
+	}
+
+	// cgroup v1: a value close to the maximum int64 when there is no limit
+	if fields, ok := readFields(fsys, "sys/fs/cgroup/memory/memory.limit_in_bytes"); ok && len(fields) == 1 {
+		return megabytesFromBytes(fields[0])
+	}
+	return 0, false
+}
+
+func megabytesFromBytes(field string) (int, bool) {
+	bytes, err := strconv.ParseUint(field, 10, 64)
+	if err != nil || bytes == 0 || bytes >= math.MaxInt64/2 {
+		return 0, false
+	}
+	return int(bytes / (1024 * 1024)), true
+}
+
+func hostMemoryMB(fsys fs.FS) (int, bool) {
+	content, err := fs.ReadFile(fsys, "proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		// MemTotal:       16318720 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "MemTotal:" && fields[2] == "kB" {
+			kilobytes, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0, false
+			}
+			return kilobytes / 1024, true
+		}
+	}
+	return 0, false
+}
+
+func readFields(fsys fs.FS, name string) ([]string, bool) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, false
+	}
+	return strings.Fields(string(content)), true
+}
@@ -0,0 +1,111 @@
+package tool
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testMeminfo = "MemTotal:       16384000 kB\nMemFree:         8192000 kB\n"
+
+func TestDetectResourcesWithCgroupV2Limits(t *testing.T) {
+	// Arrange
+	fsys := fstest.MapFS{
+		"proc/meminfo":             {Data: []byte(testMeminfo)},
+		"proc/self/cgroup":         {Data: []byte("0::/\n")},
+		"sys/fs/cgroup/cpu.max":    {Data: []byte("150000 100000\n")},
+		"sys/fs/cgroup/memory.max": {Data: []byte("4294967296\n")},
+	}
+
+	// Act
+	resources := detectResources(fsys, 16)
+
+	// Assert
+	assert.Equal(t, resourceBudget{cpus: 2, memoryMB: 4096}, resources)
+}
+
+func TestDetectResourcesWithNestedCgroupV2Limits(t *testing.T) {
+	// Arrange
+	fsys := fstest.MapFS{
+		"proc/meminfo":     {Data: []byte(testMeminfo)},
+		"proc/self/cgroup": {Data: []byte("0::/system.slice/job.scope\n")},
+		"sys/fs/cgroup/system.slice/job.scope/cpu.max":    {Data: []byte("400000 100000\n")},
+		"sys/fs/cgroup/system.slice/job.scope/memory.max": {Data: []byte("max\n")},
+	}
+
+	// Act
+	resources := detectResources(fsys, 16)
+
+	// Assert
+	assert.Equal(t, resourceBudget{cpus: 4, memoryMB: 16000}, resources)
+}
+
+func TestDetectResourcesWithUnlimitedCgroupV2(t *testing.T) {
+	// Arrange
+	fsys := fstest.MapFS{
+		"proc/meminfo":             {Data: []byte(testMeminfo)},
+		"sys/fs/cgroup/cpu.max":    {Data: []byte("max 100000\n")},
+		"sys/fs/cgroup/memory.max": {Data: []byte("max\n")},
+	}
+
+	// Act
+	resources := detectResources(fsys, 8)
+
+	// Assert
+	assert.Equal(t, resourceBudget{cpus: 8, memoryMB: 16000}, resources)
+}
+
+func TestDetectResourcesWithCgroupV1Limits(t *testing.T) {
+	// Arrange
+	fsys := fstest.MapFS{
+		"proc/meminfo": {Data: []byte(testMeminfo)},
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  {Data: []byte("300000\n")},
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": {Data: []byte("100000\n")},
+		"sys/fs/cgroup/memory/memory.limit_in_bytes":  {Data: []byte("2147483648\n")},
+	}
+
+	// Act
+	resources := detectResources(fsys, 16)
+
+	// Assert
+	assert.Equal(t, resourceBudget{cpus: 3, memoryMB: 2048}, resources)
+}
+
+func TestDetectResourcesWithUnlimitedCgroupV1(t *testing.T) {
+	// Arrange
+	fsys := fstest.MapFS{
+		"proc/meminfo":                               {Data: []byte(testMeminfo)},
+		"sys/fs/cgroup/cpu/cpu.cfs_quota_us":         {Data: []byte("-1\n")},
+		"sys/fs/cgroup/cpu/cpu.cfs_period_us":        {Data: []byte("100000\n")},
+		"sys/fs/cgroup/memory/memory.limit_in_bytes": {Data: []byte("9223372036854771712\n")},
+	}
+
+	// Act
+	resources := detectResources(fsys, 4)
+
+	// Assert
+	assert.Equal(t, resourceBudget{cpus: 4, memoryMB: 16000}, resources)
+}
+
+func TestDetectResourcesWithoutCgroupOrMeminfo(t *testing.T) {
+	// Act
+	resources := detectResources(fstest.MapFS{}, 4)
+
+	// Assert
+	assert.Equal(t, resourceBudget{cpus: 4, memoryMB: fallbackMemoryMB}, resources)
+	assert.Equal(t, 5000, resources.usableMemoryMB())
+}
+
+func TestDetectResourcesCPUQuotaNeverAboveHost(t *testing.T) {
+	// Arrange
+	fsys := fstest.MapFS{
+		"sys/fs/cgroup/cpu.max": {Data: []byte("3200000 100000\n")},
+	}
+
+	// Act
+	resources := detectResources(fsys, 4)
+
+	// Assert
+	assert.Equal(t, 4, resources.cpus)
+}
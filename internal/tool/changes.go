@@ -0,0 +1,242 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/samber/lo"
+)
+
+const (
+	diffBaseEnvironmentVariable             = "CODACY_SEMGREP_DIFF_BASE"
+	diffHeadEnvironmentVariable             = "CODACY_SEMGREP_DIFF_HEAD"
+	diffMergeBaseEnvironmentVariable        = "CODACY_SEMGREP_DIFF_MERGE_BASE"
+	diffChangedLinesOnlyEnvironmentVariable = "CODACY_SEMGREP_DIFF_CHANGED_LINES_ONLY"
+	defaultDiffHead                         = "HEAD"
+)
+
+// DiffScope limits an analysis to the changes of the git repository of the source directory,
+// like the changes of a pull request. Without Base, the whole source directory is analysed.
+type DiffScope struct {
+	// Base is the revision the changes are made on, like main or a commit hash.
+	Base string
+	// Head is the revision with the changes, HEAD by default.
+	// The source directory is expected to have the files of Head.
+	Head string
+	// MergeBase compares Head with the merge base of Base and Head instead of Base,
+	// so the changes made on Base since Head branched off aren't analysed.
+	MergeBase bool
+	// ChangedLinesOnly only reports the issues on the lines added or changed since Base.
+	ChangedLinesOnly bool
+}
+
+func (d DiffScope) enabled() bool {
+	return d.Base != ""
+}
+
+// environmentDiffScope resolves the diff scope with the environment of the process,
+// which takes precedence over the configured one.
+func environmentDiffScope(scope DiffScope) (DiffScope, error) {
+	if value, ok := os.LookupEnv(diffBaseEnvironmentVariable); ok {
+		scope.Base = strings.TrimSpace(value)
+	}
+	if value, ok := os.LookupEnv(diffHeadEnvironmentVariable); ok {
+		scope.Head = strings.TrimSpace(value)
+	}
+	for variable, field := range map[string]*bool{
+		diffMergeBaseEnvironmentVariable:        &scope.MergeBase,
+		diffChangedLinesOnlyEnvironmentVariable: &scope.ChangedLinesOnly,
+	} {
+		value, ok := os.LookupEnv(variable)
+		if !ok {
+			continue
+		}
+		parsedValue, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return scope, fmt.Errorf("invalid semgrep diff setting %s: %w", variable, err)
+		}
+		*field = parsedValue
+	}
+	if scope.Head == "" {
+		scope.Head = defaultDiffHead
+	}
+	return scope, nil
+}
+
+// sourceChanges are the files of the source directory changed between two revisions, relative to the source directory.
+type sourceChanges struct {
+	files map[string]fileChanges
+}
+
+// fileChanges are the lines added or changed in a file.
+type fileChanges struct {
+	lines []lineSpan
+	// wholeFile is set when the lines can't be known, like for binary files
+	wholeFile bool
+}
+
+// lineSpan are the lines from start to end, both included.
+type lineSpan struct {
+	start int
+	end   int
+}
+
+// diffSourceChanges finds the changes of the diff scope in the git repository of the source directory,
+// which can be a subdirectory of the repository. Renamed files are reported with their new name.
+func diffSourceChanges(ctx context.Context, sourceDir string, scope DiffScope) (*sourceChanges, error) {
+	absoluteSourceDir, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+	repository, err := git.PlainOpenWithOptions(absoluteSourceDir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository of %s\n%w", sourceDir, err)
+	}
+	worktree, err := repository.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git worktree of %s\n%w", sourceDir, err)
+	}
+	sourcePrefix, err := sourceDirPrefix(worktree.Filesystem.Root(), absoluteSourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	headCommit, err := resolveCommit(repository, scope.Head)
+	if err != nil {
+		return nil, err
+	}
+	baseCommit, err := resolveCommit(repository, scope.Base)
+	if err != nil {
+		return nil, err
+	}
+	if scope.MergeBase {
+		mergeBases, err := baseCommit.MergeBase(headCommit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find merge base of %s and %s\n%w", scope.Base, scope.Head, err)
+		}
+		if len(mergeBases) == 0 {
+			return nil, fmt.Errorf("no merge base of %s and %s", scope.Base, scope.Head)
+		}
+		baseCommit = mergeBases[0]
+	}
+
+	baseTree, err := baseCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, baseTree, headTree, &object.DiffTreeOptions{DetectRenames: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s and %s\n%w", scope.Base, scope.Head, err)
+	}
+
+	result := &sourceChanges{files: map[string]fileChanges{}}
+	for _, change := range changes {
+		// Deleted files have nothing to analyse
+		if change.To.Name == "" {
+			continue
+		}
+		file, ok := strings.CutPrefix(change.To.Name, sourcePrefix)
+		if !ok {
+			continue
+		}
+		patch, err := change.PatchContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s\n%w", change.To.Name, err)
+		}
+		result.files[file] = newFileChanges(patch)
+	}
+	return result, nil
+}
+
+// sourceDirPrefix returns the path of the source directory in the repository, as a prefix of the paths of git.
+func sourceDirPrefix(repositoryRoot, absoluteSourceDir string) (string, error) {
+	relativeSourceDir, err := filepath.Rel(repositoryRoot, absoluteSourceDir)
+	if err != nil {
+		return "", err
+	}
+	if relativeSourceDir == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(relativeSourceDir) + "/", nil
+}
+
+func resolveCommit(repository *git.Repository, revision string) (*object.Commit, error) {
+	hash, err := repository.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve git revision %s\n%w", revision, err)
+	}
+	commit, err := repository.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git commit %s\n%w", revision, err)
+	}
+	return commit, nil
+}
+
+// newFileChanges returns the lines the patch of a file adds, in the new version of the file.
+func newFileChanges(patch *object.Patch) fileChanges {
+	changes := fileChanges{}
+	for _, filePatch := range patch.FilePatches() {
+		if filePatch.IsBinary() {
+			return fileChanges{wholeFile: true}
+		}
+		line := 1
+		for _, chunk := range filePatch.Chunks() {
+			count := strings.Count(chunk.Content(), "\n") + lastLineCount([]byte(chunk.Content()))
+			switch chunk.Type() {
+			case diff.Equal:
+				line += count
+			case diff.Add:
+				changes.lines = append(changes.lines, lineSpan{start: line, end: line + count - 1})
+				line += count
+			}
+		}
+	}
+	return changes
+}
+
+// changed reports whether one of the lines from start to end was changed.
+func (c fileChanges) changed(start, end int) bool {
+	return c.wholeFile || lo.ContainsBy(c.lines, func(lines lineSpan) bool {
+		return lines.start <= end && start <= lines.end
+	})
+}
+
+// keepChangedFiles removes from the plan the files that didn't change.
+func (p *analysisPlan) keepChangedFiles(changes *sourceChanges) {
+	p.files = lo.Filter(p.files, func(file string, _ int) bool {
+		if _, changed := changes.files[filepath.ToSlash(filepath.Clean(file))]; changed {
+			return true
+		}
+		delete(p.languagesByFile, file)
+		return false
+	})
+}
+
+// filterChangedLines removes the issues that aren't on the changed lines of their file,
+// when the analysis only reports those.
+func (p *analysisPlan) filterChangedLines(results []codacy.Result) []codacy.Result {
+	if p.changes == nil || !p.changedLinesOnly {
+		return results
+	}
+	return lo.Filter(results, func(result codacy.Result, _ int) bool {
+		issue, ok := result.(Issue)
+		if !ok {
+			return true
+		}
+		changes := p.changes.files[filepath.ToSlash(filepath.Clean(issue.File))]
+		return changes.changed(issue.Range.Start.Line, max(issue.Range.Start.Line, issue.Range.End.Line))
+	})
+}
@@ -0,0 +1,228 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+// testRepository is a git repository in a temporary directory, to commit files to.
+type testRepository struct {
+	t          *testing.T
+	dir        string
+	repository *git.Repository
+	worktree   *git.Worktree
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	dir := t.TempDir()
+	repository, err := git.PlainInit(dir, false)
+	assert.NoError(t, err)
+	worktree, err := repository.Worktree()
+	assert.NoError(t, err)
+	return &testRepository{t: t, dir: dir, repository: repository, worktree: worktree}
+}
+
+// commit writes the files, removes the files with an empty content, and commits all the changes.
+func (r *testRepository) commit(files map[string]string) plumbing.Hash {
+	for file, content := range files {
+		filePath := filepath.Join(r.dir, file)
+		if content == "" {
+			assert.NoError(r.t, os.Remove(filePath))
+			continue
+		}
+		assert.NoError(r.t, os.MkdirAll(filepath.Dir(filePath), 0o755))
+		assert.NoError(r.t, os.WriteFile(filePath, []byte(content), 0o644))
+	}
+	assert.NoError(r.t, r.worktree.AddWithOptions(&git.AddOptions{All: true}))
+	hash, err := r.worktree.Commit("commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	assert.NoError(r.t, err)
+	return hash
+}
+
+func (r *testRepository) branch(name string, hash plumbing.Hash) {
+	assert.NoError(r.t, r.repository.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), hash)))
+}
+
+func TestDiffSourceChanges(t *testing.T) {
+	// Arrange
+	repository := newTestRepository(t)
+	base := repository.commit(map[string]string{
+		"app.py":     "a = 1\nb = 2\nc = 3\n",
+		"old.py":     "import os\nimport sys\nimport json\nprint(os, sys, json)\n",
+		"deleted.py": "x = 1\n",
+		"same.py":    "y = 1\n",
+	})
+	repository.branch("main", base)
+	repository.commit(map[string]string{
+		"app.py":     "a = 1\nb = eval(x)\nc = 3\nd = 4\n",
+		"old.py":     "",
+		"new.py":     "import os\nimport sys\nimport json\nprint(os, sys, json)\n",
+		"deleted.py": "",
+		"added.py":   "z = 1\n",
+	})
+
+	// Act
+	changes, err := diffSourceChanges(context.Background(), repository.dir, DiffScope{Base: "main", Head: "HEAD"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]fileChanges{
+		"app.py":   {lines: []lineSpan{{start: 2, end: 2}, {start: 4, end: 4}}},
+		"new.py":   {},
+		"added.py": {lines: []lineSpan{{start: 1, end: 1}}},
+	}, changes.files)
+}
+
+func TestDiffSourceChangesWithMergeBase(t *testing.T) {
+	// Arrange
+	repository := newTestRepository(t)
+	branchOff := repository.commit(map[string]string{"app.py": "a = 1\n", "lib.py": "b = 1\n"})
+	feature := repository.commit(map[string]string{"app.py": "a = 2\n"})
+	assert.NoError(t, repository.worktree.Checkout(&git.CheckoutOptions{Hash: branchOff}))
+	main := repository.commit(map[string]string{"lib.py": "b = 2\n"})
+	repository.branch("main", main)
+	repository.branch("feature", feature)
+
+	// Act
+	againstBase, baseErr := diffSourceChanges(context.Background(), repository.dir, DiffScope{Base: "main", Head: "feature"})
+	againstMergeBase, mergeBaseErr := diffSourceChanges(context.Background(), repository.dir, DiffScope{Base: "main", Head: "feature", MergeBase: true})
+
+	// Assert
+	assert.NoError(t, baseErr)
+	assert.NoError(t, mergeBaseErr)
+	assert.ElementsMatch(t, []string{"app.py", "lib.py"}, lo.Keys(againstBase.files))
+	assert.ElementsMatch(t, []string{"app.py"}, lo.Keys(againstMergeBase.files))
+}
+
+func TestDiffSourceChangesInSubdirectory(t *testing.T) {
+	// Arrange
+	repository := newTestRepository(t)
+	base := repository.commit(map[string]string{"service/app.py": "a = 1\n", "other/lib.py": "b = 1\n"})
+	repository.branch("main", base)
+	repository.commit(map[string]string{"service/app.py": "a = 2\n", "other/lib.py": "b = 2\n"})
+
+	// Act
+	changes, err := diffSourceChanges(context.Background(), filepath.Join(repository.dir, "service"), DiffScope{Base: "main", Head: "HEAD"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app.py"}, lo.Keys(changes.files))
+}
+
+func TestDiffSourceChangesWithUnknownRevision(t *testing.T) {
+	// Arrange
+	repository := newTestRepository(t)
+	repository.commit(map[string]string{"app.py": "a = 1\n"})
+
+	// Act
+	_, err := diffSourceChanges(context.Background(), repository.dir, DiffScope{Base: "unknown", Head: "HEAD"})
+
+	// Assert
+	assert.ErrorContains(t, err, "failed to resolve git revision unknown")
+}
+
+func TestDiffSourceChangesWithoutRepository(t *testing.T) {
+	// Act
+	_, err := diffSourceChanges(context.Background(), t.TempDir(), DiffScope{Base: "main", Head: "HEAD"})
+
+	// Assert
+	assert.ErrorContains(t, err, "failed to open git repository")
+}
+
+func TestKeepChangedFiles(t *testing.T) {
+	// Arrange
+	plan := newAnalysisPlan("/src")
+	for _, file := range []string{"app.py", "./lib/util.py", "unchanged.py"} {
+		plan.addFileToFilesByLanguage(file)
+	}
+	changes := &sourceChanges{files: map[string]fileChanges{"app.py": {}, "lib/util.py": {}, "deleted.py": {}}}
+
+	// Act
+	plan.keepChangedFiles(changes)
+
+	// Assert
+	assert.Equal(t, []string{"app.py", "./lib/util.py"}, plan.files)
+	assert.ElementsMatch(t, []string{"app.py", "./lib/util.py"}, lo.Keys(plan.languagesByFile))
+}
+
+func TestFilterChangedLines(t *testing.T) {
+	// Arrange
+	plan := newAnalysisPlan("/src")
+	plan.changes = &sourceChanges{files: map[string]fileChanges{
+		"app.py":    {lines: []lineSpan{{start: 5, end: 7}}},
+		"image.bin": {wholeFile: true},
+	}}
+	plan.changedLinesOnly = true
+	issueAt := func(file string, start, end int) Issue {
+		return Issue{
+			Issue: codacy.Issue{PatternID: "rule", File: file, Line: start},
+			Range: Range{Start: Position{Line: start}, End: Position{Line: end}},
+		}
+	}
+	onChangedLine := issueAt("app.py", 6, 6)
+	overlappingChangedLines := issueAt("app.py", 2, 5)
+	beforeChangedLines := issueAt("app.py", 2, 4)
+	afterChangedLines := issueAt("app.py", 8, 9)
+	inWholeFileChange := issueAt("image.bin", 1, 1)
+	inUnchangedFile := issueAt("other.py", 6, 6)
+	fileError := codacy.FileError{File: "other.py", Message: "Syntax error: message"}
+
+	// Act
+	results := plan.filterChangedLines([]codacy.Result{
+		onChangedLine, overlappingChangedLines, beforeChangedLines, afterChangedLines, inWholeFileChange, inUnchangedFile, fileError,
+	})
+
+	// Assert
+	assert.Equal(t, []codacy.Result{onChangedLine, overlappingChangedLines, inWholeFileChange, fileError}, results)
+}
+
+func TestFilterChangedLinesWithoutChangedLinesOnly(t *testing.T) {
+	// Arrange
+	plan := newAnalysisPlan("/src")
+	plan.changes = &sourceChanges{files: map[string]fileChanges{}}
+	results := []codacy.Result{Issue{Issue: codacy.Issue{PatternID: "rule", File: "app.py", Line: 1}}}
+
+	// Act
+	filtered := plan.filterChangedLines(results)
+
+	// Assert
+	assert.Equal(t, results, filtered)
+}
+
+func TestEnvironmentDiffScope(t *testing.T) {
+	// Arrange
+	t.Setenv(diffBaseEnvironmentVariable, " origin/main ")
+	t.Setenv(diffMergeBaseEnvironmentVariable, "true")
+	t.Setenv(diffChangedLinesOnlyEnvironmentVariable, "1")
+
+	// Act
+	scope, err := environmentDiffScope(DiffScope{Base: "main"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, DiffScope{Base: "origin/main", Head: "HEAD", MergeBase: true, ChangedLinesOnly: true}, scope)
+	assert.True(t, scope.enabled())
+}
+
+func TestEnvironmentDiffScopeWithInvalidValue(t *testing.T) {
+	// Arrange
+	t.Setenv(diffChangedLinesOnlyEnvironmentVariable, "sometimes")
+
+	// Act
+	_, err := environmentDiffScope(DiffScope{})
+
+	// Assert
+	assert.ErrorContains(t, err, "invalid semgrep diff setting CODACY_SEMGREP_DIFF_CHANGED_LINES_ONLY")
+}
@@ -2,20 +2,24 @@ package tool
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"os"
 	"os/exec"
-	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
 	"github.com/codacy/codacy-semgrep/internal/docgen"
 	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
 )
 
+const commandWaitDelay = 5 * time.Second
+
 type SemgrepOutput struct {
 	Results []SemgrepResult `json:"results"`
 	Errors  []SemgrepError  `json:"errors"`
@@ -30,16 +34,22 @@ type SemgrepResult struct {
 }
 
 type SemgrepLocation struct {
-	Line int `json:"line"`
+	Line   int `json:"line"`
+	Col    int `json:"col"`
+	Offset int `json:"offset"`
 }
 
 type SemgrepExtra struct {
-	IsIgnored   bool   `json:"is_ignored"`
-	Message     string `json:"message"`
-	RenderedFix string `json:"rendered_fix,omitempty"`
+	IsIgnored     bool                  `json:"is_ignored"`
+	Message       string                `json:"message"`
+	RenderedFix   string                `json:"rendered_fix,omitempty"`
+	DataflowTrace *SemgrepDataflowTrace `json:"dataflow_trace,omitempty"`
 }
 
 type SemgrepError struct {
+	Type     SemgrepErrorType     `json:"error_type"`
+	Severity string               `json:"severity"`
+	RuleID   string               `json:"rule_id,omitempty"`
 	Message  string               `json:"message"`
 	Location SemgrepErrorLocation `json:"location"`
 }
@@ -48,8 +58,8 @@ type SemgrepErrorLocation struct {
 	Path string `json:"path"`
 }
 
-func executeCommandForFiles(configurationFile *os.File, toolExecution codacy.ToolExecution, patternDescriptions *[]codacy.PatternDescription, language string, files []string) ([]codacy.Result, error) {
-	semgrepCmd := createCommand(configurationFile, toolExecution.SourceDir, language, files)
+func executeCommandForFiles(ctx context.Context, engine SemgrepEngine, configurationFile *os.File, sourceDir string, patternDescriptions *[]codacy.PatternDescription, language string, files []string, limits EngineLimits) ([]codacy.Result, error) {
+	semgrepCmd := createCommand(ctx, engine, configurationFile, sourceDir, language, files, limits)
 
 	semgrepOutput, semgrepError, err := runCommand(semgrepCmd)
 	if err != nil {
@@ -63,30 +73,33 @@ func executeCommandForFiles(configurationFile *os.File, toolExecution codacy.Too
 	return output, nil
 }
 
-func createCommand(configurationFile *os.File, sourceDir, language string, files []string) *exec.Cmd {
-	params := createCommandParameters(language, configurationFile, files)
-	cmd := exec.Command("semgrep", params...)
+func createCommand(ctx context.Context, engine SemgrepEngine, configurationFile *os.File, sourceDir, language string, files []string, limits EngineLimits) *exec.Cmd {
+	params := createCommandParameters(language, configurationFile, files, limits, engine.Pro)
+	cmd := exec.CommandContext(ctx, engine.Binary, params...)
 	cmd.Dir = sourceDir
+	killProcessGroupOnCancel(cmd)
+	// Don't wait forever for output pipes held by processes that survived the kill
+	cmd.WaitDelay = commandWaitDelay
 
 	return cmd
 }
 
-func createCommandParameters(language string, configurationFile *os.File, filesToAnalyse []string) []string {
+func createCommandParameters(language string, configurationFile *os.File, filesToAnalyse []string, limits EngineLimits, proEngine bool) []string {
 	cmdParams := []string{
 		"-json", "-json_nodots",
 		"-lang", language,
 		"-rules", configurationFile.Name(),
-		"-max_target_bytes", "0",
-		"-timeout", "5",
-		"-timeout_threshold", "50",
+		"-max_target_bytes", strconv.Itoa(limits.MaxTargetBytes),
+		"-timeout", strconv.Itoa(limits.Timeout),
+		"-timeout_threshold", strconv.Itoa(limits.TimeoutThreshold),
 		"-error_recovery",
-		"-max_memory", "5000",
-		"-j", strconv.Itoa(runtime.NumCPU()),
+		"-max_memory", strconv.Itoa(limits.MaxMemoryMB),
+		"-j", strconv.Itoa(limits.Jobs),
 		"-fast",
-		// adding pro features
-		// "-deep_inter_file",
-		"-deep_intra_file",
-		"-secrets",
+	}
+	if proEngine {
+		// "-deep_inter_file" is left out
+		cmdParams = append(cmdParams, proEngineFlags...)
 	}
 	// adding files to analyse
 	cmdParams = append(
@@ -130,7 +143,7 @@ func parseCommandOutput(patternDescriptions *[]codacy.PatternDescription, comman
 
 		// Process the data
 		result = appendIssueToResult(result, patternDescriptions, semgrepOutput)
-		//result = appendErrorToResult(result, semgrepOutput)
+		result = appendErrorToResult(result, semgrepOutput)
 	}
 
 	return result, nil
@@ -142,12 +155,20 @@ func appendIssueToResult(result []codacy.Result, patternDescriptions *[]codacy.P
 			continue
 		}
 
-		result = append(result, codacy.Issue{
-			PatternID:  semgrepRes.CheckID,
-			Message:    getMessage(patternDescriptions, semgrepRes.CheckID, strings.TrimSpace(semgrepRes.Extra.Message)),
-			Line:       semgrepRes.StartLocation.Line,
-			File:       semgrepRes.Path,
-			Suggestion: semgrepRes.Extra.RenderedFix,
+		trace := newDataflowTrace(semgrepRes.Extra.DataflowTrace)
+		result = append(result, Issue{
+			Issue: codacy.Issue{
+				PatternID: semgrepRes.CheckID,
+				Message:   getMessage(patternDescriptions, semgrepRes.CheckID, strings.TrimSpace(semgrepRes.Extra.Message)) + trace.messageSuffix(semgrepRes.Path),
+				Line:      semgrepRes.StartLocation.Line,
+				File:      semgrepRes.Path,
+			},
+			Range: Range{
+				Start: newPosition(semgrepRes.StartLocation),
+				End:   newPosition(semgrepRes.EndLocation),
+			},
+			Fix:   semgrepRes.Extra.RenderedFix,
+			Trace: trace,
 		})
 	}
 
@@ -169,20 +190,15 @@ func getMessage(patternDescriptions *[]codacy.PatternDescription, id string, ext
 
 func appendErrorToResult(result []codacy.Result, semgrepOutput SemgrepOutput) []codacy.Result {
 	for _, semgrepError := range semgrepOutput.Errors {
-		// Determine the size of the error message we're logging
-		sizeMessage := 250
-
-		//to avoid errors truncating messages with less than sizeMessage length
-		if sizeMessage > len(semgrepError.Message) {
-			sizeMessage = len(semgrepError.Message)
+		// Errors about the rules, not about a file, can't be reported as file errors
+		if semgrepError.Location.Path == "" {
+			logrus.Warnf("semgrep %s: %s", semgrepError.Type, semgrepError.Message)
+			continue
 		}
 
-		// The error message already with sizeMessage length
-		truncatedMessage := semgrepError.Message[:sizeMessage]
-
 		// Append the error to the result
 		result = append(result, codacy.FileError{
-			Message: truncatedMessage,
+			Message: newFileErrorMessage(classifySemgrepError(semgrepError), semgrepError.Message),
 			File:    semgrepError.Location.Path,
 		})
 	}
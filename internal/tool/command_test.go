@@ -1,10 +1,12 @@
 package tool
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 
 	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
 	"github.com/codacy/codacy-semgrep/internal/docgen"
@@ -20,7 +22,7 @@ func TestCreateCommand(t *testing.T) {
 	files := []string{"file1.go", "file2.go"}
 
 	// Act
-	cmd := createCommand(configurationFile, sourceDir, language, files)
+	cmd := createCommand(context.Background(), SemgrepEngine{Binary: DefaultSemgrepBinary, Pro: true}, configurationFile, sourceDir, language, files, DefaultEngineLimits())
 
 	// Assert
 	assert.IsType(t, &exec.Cmd{}, cmd)
@@ -34,9 +36,10 @@ func TestCreateCommandParameters(t *testing.T) {
 	defer os.Remove(configurationFile.Name())
 	language := "go"
 	filesToAnalyse := []string{"file1.go", "file2.go"}
+	limits := EngineLimits{Timeout: 5, TimeoutThreshold: 50, MaxMemoryMB: 5000, MaxTargetBytes: 0, Jobs: 4}
 
 	// Act
-	cmdParams := createCommandParameters(language, configurationFile, filesToAnalyse)
+	cmdParams := createCommandParameters(language, configurationFile, filesToAnalyse, limits, true)
 
 	// Assert
 	expectedParams := []string{
@@ -48,13 +51,30 @@ func TestCreateCommandParameters(t *testing.T) {
 		"-timeout_threshold", "50",
 		"-error_recovery",
 		"-max_memory", "5000",
+		"-j", "4",
 		"-fast",
+		"-deep_intra_file",
+		"-secrets",
 		"file1.go", "file2.go",
 	}
 
 	assert.Subset(t, cmdParams, expectedParams)
 }
 
+func TestCreateCommandParametersWithoutProEngine(t *testing.T) {
+	// Arrange
+	configurationFile, _ := os.CreateTemp("", "semgrep.yaml")
+	defer os.Remove(configurationFile.Name())
+
+	// Act
+	cmdParams := createCommandParameters("go", configurationFile, []string{"file1.go"}, DefaultEngineLimits(), false)
+
+	// Assert
+	assert.NotContains(t, cmdParams, "-deep_intra_file")
+	assert.NotContains(t, cmdParams, "-secrets")
+	assert.Equal(t, "file1.go", cmdParams[len(cmdParams)-1])
+}
+
 func TestRunCommand(t *testing.T) {
 	// Arrange
 	mockCmd := exec.Command("echo", "Testing runCommand()")
@@ -68,6 +88,23 @@ func TestRunCommand(t *testing.T) {
 	assert.Equal(t, "Testing runCommand()\n", *stdout)
 }
 
+func TestRunCommandKillsProcessGroupWhenContextEnds(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	// The background sleep keeps the output pipe open unless the whole group is killed
+	mockCmd := exec.CommandContext(ctx, "sh", "-c", "sleep 30 & sleep 30")
+	killProcessGroupOnCancel(mockCmd)
+	start := time.Now()
+
+	// Act
+	_, _, err := runCommand(mockCmd)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second, "Expected the command to be killed when the context ended")
+}
+
 func TestRunCommand_Error(t *testing.T) {
 	// Arrange
 	mockCmd := exec.Command("invalid_command_name")
@@ -89,7 +126,7 @@ func TestParseCommandOutput(t *testing.T) {
 		},
 	}
 
-	commandOutput := "{\"version\": \"1.49.0\", \"results\": [{\"check_id\": \"bash.curl.security.curl-eval.curl-eval\", \"path\": \"src/bash/curl-eval.bash\", \"start\": {\"line\": 5}, \"end\": {\"line\": 5}, \"extra\": {\"message\": \"Sample message\"}}], \"errors\": []}"
+	commandOutput := "{\"version\": \"1.49.0\", \"results\": [{\"check_id\": \"bash.curl.security.curl-eval.curl-eval\", \"path\": \"src/bash/curl-eval.bash\", \"start\": {\"line\": 5, \"col\": 3, \"offset\": 40}, \"end\": {\"line\": 7, \"col\": 12, \"offset\": 80}, \"extra\": {\"message\": \"Sample message\"}}], \"errors\": []}"
 
 	// Act
 	result, err := parseCommandOutput(&mockPatternDescriptions, commandOutput)
@@ -98,12 +135,14 @@ func TestParseCommandOutput(t *testing.T) {
 	assert.NoError(t, err, "Expected no error during parsing command output")
 	assert.Len(t, result, 1, "Expected length of the result slice to be 1")
 
-	parsedResult := result[0].(codacy.Issue)
+	parsedResult := result[0].(Issue)
 	assert.Equal(t, "bash.curl.security.curl-eval.curl-eval", parsedResult.PatternID, "Expected pattern ID in parsed result")
 	assert.Equal(t, "Sample message", parsedResult.Message, "Expected message description in parsed result")
 	assert.Equal(t, 5, parsedResult.Line, "Expected line number in parsed result")
 	assert.Equal(t, "src/bash/curl-eval.bash", parsedResult.File, "Expected file path in parsed result")
-	assert.Equal(t, "", parsedResult.Suggestion, "Expected suggestion in parsed result")
+	assert.Equal(t, "", parsedResult.Fix, "Expected fix in parsed result")
+	assert.Equal(t, Range{Start: Position{Line: 5, Col: 3, Offset: 40}, End: Position{Line: 7, Col: 12, Offset: 80}}, parsedResult.Range, "Expected range in parsed result")
+	assert.Nil(t, parsedResult.Trace, "Expected no dataflow trace in parsed result")
 }
 
 func TestAppendToResultWithIgnore(t *testing.T) {
@@ -159,12 +198,12 @@ func TestAppendToResultWithIgnore(t *testing.T) {
 	// Assert
 	assert.Len(t, result, 1, "Expected length of the result slice to be 1")
 
-	issueAppended := result[0].(codacy.Issue)
+	issueAppended := result[0].(Issue)
 	assert.Equal(t, "pattern_1", issueAppended.PatternID, "Expected pattern ID in appended issue")
 	assert.Equal(t, "Sample message", issueAppended.Message, "Expected message description in appended issue")
 	assert.Equal(t, 10, issueAppended.Line, "Expected line number in appended issue")
 	assert.Equal(t, "path/to/file.txt", issueAppended.File, "Expected file path in appended issue")
-	assert.Equal(t, "Suggested fix for issue", issueAppended.Suggestion, "Expected suggested fix in appended issue")
+	assert.Equal(t, "Suggested fix for issue", issueAppended.Fix, "Expected fix in appended issue")
 }
 
 func TestAppendIssueToResult(t *testing.T) {
@@ -205,12 +244,12 @@ func TestAppendIssueToResult(t *testing.T) {
 
 	lastResultIndex := len(result) - 1
 
-	if issue, ok := result[lastResultIndex].(codacy.Issue); ok {
+	if issue, ok := result[lastResultIndex].(Issue); ok {
 		assert.Equal(t, "pattern_1", issue.PatternID, "Expected pattern ID in appended issue")
 		assert.Equal(t, "Sample message", issue.Message, "Expected message description in appended issue")
 		assert.Equal(t, 10, issue.Line, "Expected line number in appended issue")
 		assert.Equal(t, "path/to/file.txt", issue.File, "Expected file path in appended issue")
-		assert.Equal(t, "Suggested fix for issue", issue.Suggestion, "Expected suggested fix in appended issue")
+		assert.Equal(t, "Suggested fix for issue", issue.Fix, "Expected fix in appended issue")
 	} else {
 		assert.Fail(t, "Appended result should be an Issue type")
 	}
@@ -239,7 +278,7 @@ func TestAppendErrorToResult(t *testing.T) {
 	assert.Equal(t, expectedResultLength, len(result))
 
 	lastResultIndex := len(result) - 1
-	resultJSON := "{\"filename\":\"path\",\"message\":\"message\"}"
+	resultJSON := "{\"filename\":\"path\",\"message\":\"Internal error: message\"}"
 	jsonBytes, err := result[lastResultIndex].ToJSON()
 
 	assert.NoError(t, err)
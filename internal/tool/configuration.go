@@ -1,9 +1,8 @@
 package tool
 
 import (
-	"bufio"
 	"fmt"
-	"io/fs"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -16,136 +15,133 @@ import (
 
 var htmlCommentRegex = regexp.MustCompile(`<!--\s*([A-Z_]+)\s*-->`)
 
-const sourceConfigurationFileName = ".semgrep.yaml"
+// rulesDefinitionFileName is the file of the docs directory with the definitions of the rules of every pattern.
+const rulesDefinitionFileName = "rules.yaml"
 
-// TODO: should respect cli flag for docs location
-const rulesDefinitionFileName = "/docs/rules.yaml"
-
-func newConfigurationFile(toolExecution codacy.ToolExecution) (*os.File, error) {
+func newConfigurationFile(toolExecution codacy.ToolExecution, mode SourceConfigurationMode, docsDir string) (*os.File, error) {
 
 	if toolExecution.Patterns == nil {
 		// Use the tool's configuration file, if it exists.
 		// Otherwise use the tool's default patterns.
 		if sourceConfigurationFileExists(toolExecution.SourceDir) {
+			if mode == SourceConfigurationMerge {
+				return createMergedConfigurationFile(docsDir, enabledPatterns(*toolExecution.ToolDefinition.Patterns), toolExecution.SourceDir)
+			}
 			return getSourceConfigurationFile(toolExecution.SourceDir)
 		}
 
-		return createConfigurationFileFromDefaultPatterns(*toolExecution.ToolDefinition.Patterns)
+		return createConfigurationFileFromDefaultPatterns(docsDir, *toolExecution.ToolDefinition.Patterns)
 	}
 
 	if len(*toolExecution.Patterns) == 0 {
 		return nil, nil
 	}
 
+	// In merge mode, the rules of the tool's configuration file are added to the configured patterns
+	if mode == SourceConfigurationMerge && sourceConfigurationFileExists(toolExecution.SourceDir) {
+		return createMergedConfigurationFile(docsDir, *toolExecution.Patterns, toolExecution.SourceDir)
+	}
+
 	// if there are configured patterns, create a configuration file from them
-	return createConfigurationFileFromPatterns(toolExecution.Patterns)
+	return createConfigurationFileFromPatterns(docsDir, toolExecution.Patterns)
 }
 
 func sourceConfigurationFileExists(sourceDir string) bool {
-	if fileInfo, err := os.Stat(path.Join(sourceDir, sourceConfigurationFileName)); err != nil || fileInfo.IsDir() {
-		return false
+	_, found := findSourceConfiguration(sourceDir)
+	return found
+}
+
+// cleanUpConfigurationFile closes the configuration file and, unless it is one from the
+// source directory, deletes it.
+func cleanUpConfigurationFile(configurationFile *os.File, sourceDir string) {
+	configurationFile.Close()
+	if !isSourceConfigurationFile(configurationFile.Name(), sourceDir) {
+		os.Remove(configurationFile.Name())
 	}
+}
 
-	return true
+func createConfigurationFileFromDefaultPatterns(docsDir string, patterns []codacy.Pattern) (*os.File, error) {
+	defaultPatterns := enabledPatterns(patterns)
+	return createConfigurationFileFromPatterns(docsDir, &defaultPatterns)
 }
 
-func createConfigurationFileFromDefaultPatterns(patterns []codacy.Pattern) (*os.File, error) {
-	defaultPatterns := lo.Filter(patterns, func(pattern codacy.Pattern, _ int) bool {
+func enabledPatterns(patterns []codacy.Pattern) []codacy.Pattern {
+	return lo.Filter(patterns, func(pattern codacy.Pattern, _ int) bool {
 		return pattern.Enabled
 	})
-	return createConfigurationFileFromPatterns(&defaultPatterns)
 }
 
+// getSourceConfigurationFile opens the configuration file of the source directory.
+// The rule files of a configuration directory are merged into a new configuration file.
 func getSourceConfigurationFile(sourceFolder string) (*os.File, error) {
-	filename := path.Join(sourceFolder, sourceConfigurationFileName)
-	file, err := os.Open(filename)
+	configuration, found := findSourceConfiguration(sourceFolder)
+	if !found {
+		return nil, fmt.Errorf("no semgrep configuration found in %s", sourceFolder)
+	}
+	if !configuration.isDir {
+		return os.Open(path.Join(sourceFolder, configuration.name))
+	}
+
+	rules, err := configuration.readRuleSet()
 	if err != nil {
 		return nil, err
 	}
-	return file, nil
+	return writeConfigurationFile(rules)
 }
 
-func createConfigurationFileFromPatterns(patterns *[]codacy.Pattern) (*os.File, error) {
+func createConfigurationFileFromPatterns(docsDir string, patterns *[]codacy.Pattern) (*os.File, error) {
 
-	defaultConfigurationFileScanner, err := newRulesScanner()
+	rulesDefinitionFile, err := os.Open(filepath.Join(docsDir, rulesDefinitionFileName))
 	if err != nil {
 		return nil, err
 	}
+	defer rulesDefinitionFile.Close()
 
-	configurationFile, err := createAndWriteConfigurationFile(defaultConfigurationFileScanner, patterns)
+	configurationFile, err := createAndWriteConfigurationFile(rulesDefinitionFile, patterns)
 	if err != nil {
 		return nil, err
 	}
 	return configurationFile, nil
 }
 
-func newRulesScanner() (*bufio.Scanner, error) {
-
-	rulesConfigurationFile, err := os.Open(rulesDefinitionFileName)
+// createAndWriteConfigurationFile writes a configuration file with the rules definitions configured by the patterns.
+func createAndWriteConfigurationFile(rulesDefinition io.Reader, patterns *[]codacy.Pattern) (*os.File, error) {
+	rules, err := readRuleSet(rulesDefinition, rulesDefinitionFileName)
 	if err != nil {
 		return nil, err
 	}
 
-	defaultConfigurationFileScanner := bufio.NewScanner(rulesConfigurationFile)
-
-	return defaultConfigurationFileScanner, nil
+	return writeConfigurationFile(rules.selectPatterns(*patterns))
 }
 
-func createAndWriteConfigurationFile(scanner *bufio.Scanner, patterns *[]codacy.Pattern) (*os.File, error) {
-	configurationFile, err := os.CreateTemp(os.TempDir(), "semgrep-*.yaml")
+func createMergedConfigurationFile(docsDir string, patterns []codacy.Pattern, sourceDir string) (*os.File, error) {
+	rulesDefinitionFile, err := os.Open(filepath.Join(docsDir, rulesDefinitionFileName))
 	if err != nil {
 		return nil, err
 	}
-	_, err = configurationFile.WriteString("rules:\n")
+	defer rulesDefinitionFile.Close()
+
+	configuration, found := findSourceConfiguration(sourceDir)
+	if !found {
+		return nil, fmt.Errorf("no semgrep configuration found in %s", sourceDir)
+	}
+	sourceRules, err := configuration.readRuleSet()
 	if err != nil {
 		return nil, err
 	}
 
-	idIsPresent := false
-	var currentPattern *codacy.Pattern
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		idIsPresent, currentPattern = defaultRuleIsConfiguredWithPattern(line, patterns, idIsPresent, currentPattern)
-		if idIsPresent {
-			// Replace HTML comment placeholders with parameter values
-			processedLine := replaceParameterPlaceholders(line, currentPattern)
-			_, err := configurationFile.WriteString(processedLine + "\n")
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
-	return configurationFile, nil
+	return createAndWriteMergedConfigurationFile(rulesDefinitionFile, patterns, sourceRules, configuration.name)
 }
 
-func defaultRuleIsConfigured(line string, patterns *[]codacy.Pattern, idIsPresent bool) bool {
-	if strings.Contains(line, "- id:") {
-		id := strings.TrimSpace(strings.Split(line, ":")[1])
-		return isIDPresent(id, patterns)
-	}
-	return idIsPresent // We want to keep the same value
-}
-
-func defaultRuleIsConfiguredWithPattern(line string, patterns *[]codacy.Pattern, idIsPresent bool, currentPattern *codacy.Pattern) (bool, *codacy.Pattern) {
-	if strings.Contains(line, "- id:") {
-		id := strings.TrimSpace(strings.Split(line, ":")[1])
-		pattern, found := lo.Find(*patterns, func(item codacy.Pattern) bool {
-			return item.ID == id
-		})
-		if found {
-			return true, &pattern
-		}
-		return false, nil
+// createAndWriteMergedConfigurationFile writes a configuration file with the rules definitions configured by the patterns
+// merged with the rules of the source configuration.
+func createAndWriteMergedConfigurationFile(rulesDefinition io.Reader, patterns []codacy.Pattern, sourceRules *ruleSet, sourceName string) (*os.File, error) {
+	rules, err := readRuleSet(rulesDefinition, rulesDefinitionFileName)
+	if err != nil {
+		return nil, err
 	}
-	return idIsPresent, currentPattern
-}
 
-func isIDPresent(id string, patterns *[]codacy.Pattern) bool {
-	_, res := lo.Find(*patterns, func(item codacy.Pattern) bool {
-		return item.ID == id
-	})
-	return res
+	return writeConfigurationFile(mergeRuleSets(rules.selectPatterns(patterns), sourceRules, sourceName))
 }
 
 // replaceParameterPlaceholders replaces HTML comment placeholders (e.g., <!-- MODEL_REGEX -->)
@@ -232,8 +228,6 @@ func formatParameterName(name string) string {
 	return result
 }
 
-var filesByLanguage = make(map[string][]string)
-
 // Semgrep: supported language tags are: apex, bash, c, c#, c++, cairo, clojure, cpp, csharp, dart, docker, dockerfile, elixir, ex, generic, go, golang, hack, hcl, html, java, javascript, js, json, jsonnet, julia, kotlin, kt, lisp, lua, none, ocaml, php, promql, proto, proto3, protobuf, py, python, python2, python3, r, regex, ruby, rust, scala, scheme, sh, sol, solidity, swift, terraform, tf, ts, typescript, vue, xml, yaml
 // Semgrep: https://github.com/semgrep/semgrep/blob/0ec2b95ec8c3afb8e31fc0295d3604e540c982b0/src/parsing/Unit_parsing.ml#L61
 // Codacy: taken from https://github.com/codacy/ragnaros/blob/05d1374b7ca4a0aa3be44972484938b4785c046f/components/language/src/main/scala/codacy/foundation/api/Language.scala#L6
@@ -268,7 +262,6 @@ var extensionToLanguageMap = map[string]string{
 	".ts":         "typescript",
 	".tsx":        "typescript",
 	".dockerfile": "dockerfile",
-	"Dockerfile":  "dockerfile",
 	".sql":        "generic",
 	".pls":        "generic",
 	".trg":        "generic",
@@ -343,65 +336,36 @@ var extensionToLanguageMap = map[string]string{
 	".tf":  "terraform",
 }
 
-func populateFilesByLanguage(toolExecutionFiles *[]string, toolExecutionSourceDir string) error {
+func (p *analysisPlan) populateFilesByLanguage(toolExecutionFiles *[]string, toolExecutionSourceDir string) error {
 	// If there are files to analyse, analyse only those files
 	if toolExecutionFiles != nil && len(*toolExecutionFiles) > 0 {
-		return populateFilesByLanguageFromFiles(*toolExecutionFiles)
+		return p.populateFilesByLanguageFromFiles(*toolExecutionFiles)
 	}
 	// If there are no files to analyse, analyse all files from source dir
-	return populateFilesByLanguageFromSourceDir(toolExecutionSourceDir)
+	return p.populateFilesByLanguageFromSourceDir(toolExecutionSourceDir)
 }
 
-func populateFilesByLanguageFromFiles(toolExecutionFiles []string) error {
+func (p *analysisPlan) populateFilesByLanguageFromFiles(toolExecutionFiles []string) error {
 	for _, file := range toolExecutionFiles {
-		addFileToFilesByLanguage(file)
+		p.addFileToFilesByLanguage(file)
 	}
 
 	return nil
 }
 
-func populateFilesByLanguageFromSourceDir(toolExecutionSourceDir string) error {
+// populateFilesByLanguageFromSourceDir adds the files of the source directory that aren't ignored,
+// relative to the source directory like the files of a tool execution.
+func (p *analysisPlan) populateFilesByLanguageFromSourceDir(toolExecutionSourceDir string) error {
 	// Semgrep can analyse full directories and its subdirectories
 	// but we will have to analyse every extension from every file
 	// so we will have to do this walk somewhere else if we dont do it here
-	err := filepath.WalkDir(toolExecutionSourceDir, processFile)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return newSourceWalker(toolExecutionSourceDir, p.addFileToFilesByLanguage).walk()
 }
 
-func processFile(path string, info fs.DirEntry, err error) error {
-	if err != nil {
-		return err
-	}
-	pathInfo, pathErr := info.Info()
-	if pathErr != nil {
-		return pathErr
-	}
-	// if it is a file and it is not a hidden file
-	if !pathInfo.IsDir() && !strings.HasPrefix(pathInfo.Name(), ".") {
-		addFileToFilesByLanguage(path)
-	}
-
-	return nil
-}
-
-func addFileToFilesByLanguage(fileName string) {
-	language := detectLanguage(fileName)
-	filesByLanguage[language] = append(filesByLanguage[language], fileName)
-}
-
-func detectLanguage(fileName string) string {
-	extension := strings.ToLower(filepath.Ext(fileName))
-	extensionOrFilename := extension
-	if extension == "" {
-		extensionOrFilename = fileName
-	}
-
-	if language, ok := extensionToLanguageMap[extensionOrFilename]; ok {
-		return language
+func (p *analysisPlan) addFileToFilesByLanguage(fileName string) {
+	if _, ok := p.languagesByFile[fileName]; ok {
+		return
 	}
-	return "none"
+	p.files = append(p.files, fileName)
+	p.languagesByFile[fileName] = p.detectFileLanguages(fileName)
 }
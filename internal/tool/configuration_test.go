@@ -1,7 +1,6 @@
 package tool
 
 import (
-	"bufio"
 	"io/fs"
 	"os"
 	"path"
@@ -9,7 +8,9 @@ import (
 	"time"
 
 	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 )
 
 func TestSourceConfigurationFileExistsWhenFileExists(t *testing.T) {
@@ -86,6 +87,39 @@ func TestGetSourceConfigurationFileWithError(t *testing.T) {
 	assert.Nil(t, file, "Expected file to be nil due to error")
 }
 
+func TestCleanUpConfigurationFileDeletesGeneratedFile(t *testing.T) {
+	// Arrange
+	configurationFile, err := os.CreateTemp("", "semgrep-*.yaml")
+	assert.NoError(t, err)
+
+	// Act
+	cleanUpConfigurationFile(configurationFile, "./test_folder")
+
+	// Assert
+	assert.NoFileExists(t, configurationFile.Name())
+}
+
+func TestCleanUpConfigurationFileKeepsSourceFile(t *testing.T) {
+	// Arrange
+	sourceDir := "./test_folder"
+	err := os.MkdirAll(sourceDir, 0700)
+	assert.NoError(t, err)
+
+	testFilePath := path.Join(sourceDir, ".semgrep.yaml")
+	configurationFile, err := os.Create(testFilePath)
+	assert.NoError(t, err)
+	defer func() {
+		os.Remove(testFilePath)
+		os.Remove(sourceDir)
+	}()
+
+	// Act
+	cleanUpConfigurationFile(configurationFile, sourceDir)
+
+	// Assert
+	assert.FileExists(t, testFilePath)
+}
+
 func TestWriteTmpFileWhenIDIsPresent(t *testing.T) {
 	// Arrange
 	patterns := []codacy.Pattern{
@@ -99,11 +133,22 @@ func TestWriteTmpFileWhenIDIsPresent(t *testing.T) {
 		},
 	}
 
-	content := "- id: pattern123\nsome content\n- id: pattern789\nsome other content\n"
-
-	expectedContent := "rules:\n- id: pattern123\nsome content\n"
-
-	// Create a rules file to read from and write to
+	content := `rules:
+  - id: pattern123
+    languages: [go]
+    message: some content
+  - id: pattern789
+    languages: [go]
+    message: some other content
+`
+
+	expectedContent := `rules:
+  - id: pattern123
+    languages: [go]
+    message: some content
+`
+
+	// Create a rules file to read from
 	rulesFile, err := os.CreateTemp("", "rulesFile.yaml")
 	assert.NoError(t, err)
 	defer os.Remove(rulesFile.Name())
@@ -116,12 +161,10 @@ func TestWriteTmpFileWhenIDIsPresent(t *testing.T) {
 	_, err = rulesFile.Seek(0, 0)
 	assert.NoError(t, err)
 
-	// Create a scanner to read the temporary file
-	scanner := bufio.NewScanner(rulesFile)
-
 	// Act
-	resultFile, err := createAndWriteConfigurationFile(scanner, &patterns)
+	resultFile, err := createAndWriteConfigurationFile(rulesFile, &patterns)
 	assert.NoError(t, err)
+	defer os.Remove(resultFile.Name())
 
 	// Read the resulting file content
 	resultContent, err := os.ReadFile(resultFile.Name())
@@ -140,11 +183,18 @@ func TestWriteTmpFileWhenIDIsNotPresent(t *testing.T) {
 		},
 	}
 
-	content := "- id: pattern123\nsome content\n- id: pattern456\nsome other content\n"
+	content := `rules:
+  - id: pattern123
+    languages: [go]
+    message: some content
+  - id: pattern456
+    languages: [go]
+    message: some other content
+`
 
-	expectedContent := "rules:\n" // Expecting an empty file as the ID is not present
+	expectedContent := "rules: []\n" // Expecting no rules as the ID is not present
 
-	// Create a rules file to read from and write to
+	// Create a rules file to read from
 	rulesFile, err := os.CreateTemp("", "rulesFile.txt")
 	assert.NoError(t, err)
 	defer os.Remove(rulesFile.Name())
@@ -157,12 +207,10 @@ func TestWriteTmpFileWhenIDIsNotPresent(t *testing.T) {
 	_, err = rulesFile.Seek(0, 0)
 	assert.NoError(t, err)
 
-	// Create a scanner to read the rules file
-	scanner := bufio.NewScanner(rulesFile)
-
 	// Act
-	resultFile, err := createAndWriteConfigurationFile(scanner, &patterns)
+	resultFile, err := createAndWriteConfigurationFile(rulesFile, &patterns)
 	assert.NoError(t, err)
+	defer os.Remove(resultFile.Name())
 
 	// Read the resulting file content
 	resultContent, err := os.ReadFile(resultFile.Name())
@@ -172,100 +220,6 @@ func TestWriteTmpFileWhenIDIsNotPresent(t *testing.T) {
 	assert.Equal(t, expectedContent, string(resultContent), "Expected content to be an empty file as the desired ID is not present")
 }
 
-func TestInsideDesiredIDBlockWhenLineContainsID(t *testing.T) {
-	// Arrange
-	line := "- id: pattern123"
-	idIsPresent := false
-	patterns := []codacy.Pattern{
-		{
-			ID:      "pattern123",
-			Enabled: true,
-		},
-		{
-			ID:      "pattern456",
-			Enabled: true,
-		},
-	}
-
-	// Act
-	result := defaultRuleIsConfigured(line, &patterns, idIsPresent)
-
-	// Assert
-	assert.True(t, result, "Expected ID to be present in patterns")
-}
-
-func TestInsideDesiredIDBlockWhenLineDoesNotContainID(t *testing.T) {
-	// Arrange
-	line := "Some other line without an ID"
-	idIsPresent := true
-	patterns := []codacy.Pattern{
-		{
-			ID:      "pattern123",
-			Enabled: true,
-		},
-		{
-			ID:      "pattern456",
-			Enabled: true,
-		},
-	}
-
-	// Act
-	result := defaultRuleIsConfigured(line, &patterns, idIsPresent)
-
-	// Assert
-	assert.True(t, result, "Expected ID presence to remain unchanged")
-}
-
-func TestIsIDPresentWhenIDIsPresent(t *testing.T) {
-	// Arrange
-	id := "pattern123"
-	patterns := []codacy.Pattern{
-		{
-			ID:      "pattern456",
-			Enabled: true,
-		},
-		{
-			ID:      "pattern123",
-			Enabled: true,
-		},
-		{
-			ID:      "pattern789",
-			Enabled: true,
-		},
-	}
-
-	// Act
-	result := isIDPresent(id, &patterns)
-
-	// Assert
-	assert.True(t, result, "Expected ID to be present in patterns")
-}
-
-func TestIsIDPresentWhenIDIsNotPresent(t *testing.T) {
-	// Arrange
-	id := "nonExistentPattern"
-	patterns := []codacy.Pattern{
-		{
-			ID:      "pattern456",
-			Enabled: true,
-		},
-		{
-			ID:      "pattern123",
-			Enabled: true,
-		},
-		{
-			ID:      "pattern789",
-			Enabled: true,
-		},
-	}
-
-	// Act
-	result := isIDPresent(id, &patterns)
-
-	// Assert
-	assert.False(t, result, "Expected ID to be absent in patterns")
-}
-
 // MockDirEntry implements both fs.DirEntry and fs.FileInfo interfaces
 type MockDirEntry struct {
 	name     string
@@ -324,11 +278,14 @@ func TestWalkDirFuncForFileNotHidden(t *testing.T) {
 		isDir: false,
 	}
 
+	var files []string
+
 	// Act
-	err := processFile(filePath, mockDirEntry, nil)
+	err := newSourceWalker("/path/to", func(file string) { files = append(files, file) }).processFile(filePath, mockDirEntry, nil)
 
 	// Assert
 	assert.NoError(t, err)
+	assert.Equal(t, []string{"file.go"}, files)
 }
 
 func TestWalkDirFuncForDirectory(t *testing.T) {
@@ -342,7 +299,7 @@ func TestWalkDirFuncForDirectory(t *testing.T) {
 	}
 
 	// Act
-	err := processFile(dirPath, mockDirEntry, nil)
+	err := newSourceWalker("/path/to", func(string) {}).processFile(dirPath, mockDirEntry, nil)
 
 	// Assert
 	assert.NoError(t, err)
@@ -358,11 +315,14 @@ func TestWalkDirFuncForHiddenFile(t *testing.T) {
 		err:      nil,
 	}
 
+	var files []string
+
 	// Act
-	err := processFile(hiddenFilePath, mockDirEntry, nil)
+	err := newSourceWalker("/path/to", func(file string) { files = append(files, file) }).processFile(hiddenFilePath, mockDirEntry, nil)
 
 	// Assert
 	assert.NoError(t, err)
+	assert.Empty(t, files)
 }
 
 func TestWalkDirFuncWithPathError(t *testing.T) {
@@ -376,7 +336,7 @@ func TestWalkDirFuncWithPathError(t *testing.T) {
 	}
 
 	// Act
-	err := processFile(filePath, mockDirEntry, nil)
+	err := newSourceWalker("/path/to", func(string) {}).processFile(filePath, mockDirEntry, nil)
 
 	// Assert
 	assert.Error(t, err)
@@ -392,7 +352,7 @@ func TestWalkDirFuncWithError(t *testing.T) {
 	}
 
 	// Act
-	err := processFile(filePath, mockDirEntry, assert.AnError)
+	err := newSourceWalker("/path/to", func(string) {}).processFile(filePath, mockDirEntry, assert.AnError)
 
 	// Assert
 	assert.Error(t, err)
@@ -402,33 +362,39 @@ func TestAddFileToFilesByLanguageWithGoFile(t *testing.T) {
 	// Arrange
 	fileName := "file.go"
 
+	plan := newAnalysisPlan("")
+
 	// Act
-	addFileToFilesByLanguage(fileName)
+	plan.addFileToFilesByLanguage(fileName)
 
 	// Assert
-	assert.Contains(t, filesByLanguage["go"], fileName, "Expected file to be added to Go files")
+	assert.Contains(t, plan.filesByLanguage()["go"], fileName, "Expected file to be added to Go files")
 }
 
 func TestAddFileToFilesByLanguageWithPythonFile(t *testing.T) {
 	// Arrange
 	fileName := "script.py"
 
+	plan := newAnalysisPlan("")
+
 	// Act
-	addFileToFilesByLanguage(fileName)
+	plan.addFileToFilesByLanguage(fileName)
 
 	// Assert
-	assert.Contains(t, filesByLanguage["python"], fileName, "Expected file to be added to Python files")
+	assert.Contains(t, plan.filesByLanguage()["python"], fileName, "Expected file to be added to Python files")
 }
 
 func TestAddFileToFilesByLanguageWithUnknownFile(t *testing.T) {
 	// Arrange
 	fileName := "document.docx"
 
+	plan := newAnalysisPlan("")
+
 	// Act
-	addFileToFilesByLanguage(fileName)
+	plan.addFileToFilesByLanguage(fileName)
 
 	// Assert
-	assert.Contains(t, filesByLanguage, "none", "Expected file to be added to unknown language")
+	assert.Contains(t, plan.filesByLanguage(), "none", "Expected file to be added to unknown language")
 }
 
 func TestDetectLanguageWithGoExtension(t *testing.T) {
@@ -474,3 +440,27 @@ func TestDetectLanguageWithoutExtension(t *testing.T) {
 	// Assert
 	assert.Equal(t, "none", language, "Expected language to be none for unknown file type")
 }
+
+func TestNewConfigurationFileReadsRulesFromDocsDir(t *testing.T) {
+	// Arrange
+	docsDir := writeSourceFiles(t, map[string]string{
+		rulesDefinitionFileName: "rules:\n" + sourceRule("codacy.python.eval") + sourceRule("codacy.python.exec"),
+	})
+	toolExecution := codacy.ToolExecution{
+		SourceDir:      t.TempDir(),
+		ToolDefinition: codacy.ToolDefinition{Patterns: &[]codacy.Pattern{}},
+		Patterns:       &[]codacy.Pattern{{ID: "codacy.python.exec"}},
+	}
+
+	// Act
+	configurationFile, err := newConfigurationFile(toolExecution, SourceConfigurationReplace, docsDir)
+
+	// Assert
+	assert.NoError(t, err)
+	defer cleanUpConfigurationFile(configurationFile, toolExecution.SourceDir)
+	rules, err := readRuleFile(configurationFile.Name(), configurationFile.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"codacy.python.exec"}, lo.Map(rules.rules, func(rule *yaml.Node, _ int) string {
+		return ruleID(rule)
+	}))
+}
@@ -0,0 +1,212 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxTraceContentLength is the length above which the code of a trace step is shortened in issue messages.
+const maxTraceContentLength = 40
+
+// SemgrepDataflowTrace is the trace semgrep reports for the results of taint rules.
+type SemgrepDataflowTrace struct {
+	TaintSource      *SemgrepCallTrace        `json:"taint_source,omitempty"`
+	IntermediateVars []SemgrepIntermediateVar `json:"intermediate_vars,omitempty"`
+	TaintSink        *SemgrepCallTrace        `json:"taint_sink,omitempty"`
+}
+
+type SemgrepIntermediateVar struct {
+	Location SemgrepTraceLocation `json:"location"`
+	Content  string               `json:"content,omitempty"`
+}
+
+type SemgrepTraceLocation struct {
+	Path  string          `json:"path"`
+	Start SemgrepLocation `json:"start"`
+	End   SemgrepLocation `json:"end"`
+}
+
+// SemgrepCallTrace is a taint source or sink, or a call that leads to one.
+// semgrep encodes it as a variant: ["CliLoc", [location, content]] or ["CliCall", [[location, content], vars, trace]],
+// and without the content as ["CoreLoc", location] or ["CoreCall", [location, vars, trace]].
+type SemgrepCallTrace struct {
+	Location         SemgrepTraceLocation
+	Content          string
+	IntermediateVars []SemgrepIntermediateVar
+	Callee           *SemgrepCallTrace
+}
+
+func (t *SemgrepCallTrace) UnmarshalJSON(data []byte) error {
+	var variant []json.RawMessage
+	if err := json.Unmarshal(data, &variant); err != nil {
+		return err
+	}
+	if len(variant) != 2 {
+		return fmt.Errorf("invalid semgrep call trace: %s", data)
+	}
+	var kind string
+	if err := json.Unmarshal(variant[0], &kind); err != nil {
+		return err
+	}
+
+	switch kind {
+	case "CoreLoc":
+		return json.Unmarshal(variant[1], &t.Location)
+	case "CliLoc":
+		return t.unmarshalLocationWithContent(variant[1])
+	case "CoreCall", "CliCall":
+		var call []json.RawMessage
+		if err := json.Unmarshal(variant[1], &call); err != nil {
+			return err
+		}
+		if len(call) != 3 {
+			return fmt.Errorf("invalid semgrep call trace: %s", data)
+		}
+		var err error
+		if kind == "CoreCall" {
+			err = json.Unmarshal(call[0], &t.Location)
+		} else {
+			err = t.unmarshalLocationWithContent(call[0])
+		}
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(call[1], &t.IntermediateVars); err != nil {
+			return err
+		}
+		t.Callee = &SemgrepCallTrace{}
+		return json.Unmarshal(call[2], t.Callee)
+	default:
+		return fmt.Errorf("unknown semgrep call trace: %s", kind)
+	}
+}
+
+func (t *SemgrepCallTrace) unmarshalLocationWithContent(data []byte) error {
+	var locationWithContent []json.RawMessage
+	if err := json.Unmarshal(data, &locationWithContent); err != nil {
+		return err
+	}
+	if len(locationWithContent) != 2 {
+		return fmt.Errorf("invalid semgrep trace location: %s", data)
+	}
+	if err := json.Unmarshal(locationWithContent[0], &t.Location); err != nil {
+		return err
+	}
+	return json.Unmarshal(locationWithContent[1], &t.Content)
+}
+
+// DataflowTrace is how tainted data flows from its source to the sink of a taint rule.
+type DataflowTrace struct {
+	Source *CallTrace
+	// IntermediateVars are the variables the tainted data goes through, in order
+	IntermediateVars []TraceLocation
+	Sink             *CallTrace
+}
+
+// CallTrace is where a taint source or sink is. When it is in another function,
+// the location is the call of the function, and Callee traces the source or sink from there.
+type CallTrace struct {
+	Location         TraceLocation
+	IntermediateVars []TraceLocation
+	Callee           *CallTrace
+}
+
+// TraceLocation is a step of a dataflow trace and its code.
+// The code is empty when semgrep doesn't report it.
+type TraceLocation struct {
+	File    string
+	Range   Range
+	Content string
+}
+
+func newDataflowTrace(trace *SemgrepDataflowTrace) *DataflowTrace {
+	if trace == nil {
+		return nil
+	}
+	return &DataflowTrace{
+		Source:           newCallTrace(trace.TaintSource),
+		IntermediateVars: newIntermediateVars(trace.IntermediateVars),
+		Sink:             newCallTrace(trace.TaintSink),
+	}
+}
+
+func newCallTrace(trace *SemgrepCallTrace) *CallTrace {
+	if trace == nil {
+		return nil
+	}
+	return &CallTrace{
+		Location:         newTraceLocation(trace.Location, trace.Content),
+		IntermediateVars: newIntermediateVars(trace.IntermediateVars),
+		Callee:           newCallTrace(trace.Callee),
+	}
+}
+
+func newIntermediateVars(vars []SemgrepIntermediateVar) []TraceLocation {
+	var locations []TraceLocation
+	for _, v := range vars {
+		locations = append(locations, newTraceLocation(v.Location, v.Content))
+	}
+	return locations
+}
+
+func newTraceLocation(location SemgrepTraceLocation, content string) TraceLocation {
+	return TraceLocation{
+		File:    location.Path,
+		Range:   Range{Start: newPosition(location.Start), End: newPosition(location.End)},
+		Content: content,
+	}
+}
+
+// Origin returns where the source or sink of the trace actually is, following the calls that lead to it.
+func (t *CallTrace) Origin() TraceLocation {
+	for t.Callee != nil {
+		t = t.Callee
+	}
+	return t.Location
+}
+
+// messageSuffix describes the trace for the message of an issue of a file, like
+// " Tainted data flows from `request.args` (line 3) through `query` (line 5) to `execute(query)` (line 9).".
+// It is empty when there is no trace.
+func (t *DataflowTrace) messageSuffix(file string) string {
+	if t == nil || (t.Source == nil && t.Sink == nil) {
+		return ""
+	}
+
+	var suffix strings.Builder
+	suffix.WriteString(" Tainted data flows")
+	if t.Source != nil {
+		suffix.WriteString(" from " + describeTraceLocation(t.Source.Origin(), file))
+	}
+	if len(t.IntermediateVars) > 0 {
+		steps := make([]string, 0, len(t.IntermediateVars))
+		for _, v := range t.IntermediateVars {
+			steps = append(steps, describeTraceLocation(v, file))
+		}
+		suffix.WriteString(" through " + strings.Join(steps, ", "))
+	}
+	if t.Sink != nil {
+		suffix.WriteString(" to " + describeTraceLocation(t.Sink.Origin(), file))
+	}
+	suffix.WriteString(".")
+	return suffix.String()
+}
+
+// describeTraceLocation describes a step of a trace by its code and its line,
+// with its file when it isn't the file of the issue.
+func describeTraceLocation(location TraceLocation, file string) string {
+	position := fmt.Sprintf("line %d", location.Range.Start.Line)
+	if location.File != "" && location.File != file {
+		position = fmt.Sprintf("%s:%d", location.File, location.Range.Start.Line)
+	}
+
+	content := strings.Join(strings.Fields(location.Content), " ")
+	if content == "" {
+		return position
+	}
+	if runes := []rune(content); len(runes) > maxTraceContentLength {
+		content = strings.TrimSpace(string(runes[:maxTraceContentLength-3])) + "..."
+	}
+	return fmt.Sprintf("`%s` (%s)", content, position)
+}
@@ -0,0 +1,188 @@
+package tool
+
+import (
+	"encoding/json"
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+func traceLocation(file string, line int, content string) TraceLocation {
+	return TraceLocation{
+		File:    file,
+		Range:   Range{Start: Position{Line: line, Col: 1}, End: Position{Line: line, Col: 1 + len(content)}},
+		Content: content,
+	}
+}
+
+func TestSemgrepCallTraceUnmarshalJSON(t *testing.T) {
+	location := `{"path": "app.py", "start": {"line": 3, "col": 5, "offset": 30}, "end": {"line": 3, "col": 17, "offset": 42}}`
+	calleeLocation := `{"path": "lib.py", "start": {"line": 8, "col": 1, "offset": 90}, "end": {"line": 8, "col": 4, "offset": 93}}`
+	expectedLocation := SemgrepTraceLocation{
+		Path:  "app.py",
+		Start: SemgrepLocation{Line: 3, Col: 5, Offset: 30},
+		End:   SemgrepLocation{Line: 3, Col: 17, Offset: 42},
+	}
+	expectedCalleeLocation := SemgrepTraceLocation{
+		Path:  "lib.py",
+		Start: SemgrepLocation{Line: 8, Col: 1, Offset: 90},
+		End:   SemgrepLocation{Line: 8, Col: 4, Offset: 93},
+	}
+
+	testCases := []struct {
+		name     string
+		trace    string
+		expected SemgrepCallTrace
+	}{
+		{
+			name:     "location",
+			trace:    `["CoreLoc", ` + location + `]`,
+			expected: SemgrepCallTrace{Location: expectedLocation},
+		},
+		{
+			name:     "location with content",
+			trace:    `["CliLoc", [` + location + `, "request.args"]]`,
+			expected: SemgrepCallTrace{Location: expectedLocation, Content: "request.args"},
+		},
+		{
+			name:  "call",
+			trace: `["CoreCall", [` + location + `, [{"location": ` + calleeLocation + `}], ["CoreLoc", ` + calleeLocation + `]]]`,
+			expected: SemgrepCallTrace{
+				Location:         expectedLocation,
+				IntermediateVars: []SemgrepIntermediateVar{{Location: expectedCalleeLocation}},
+				Callee:           &SemgrepCallTrace{Location: expectedCalleeLocation},
+			},
+		},
+		{
+			name: "call with content",
+			trace: `["CliCall", [[` + location + `, "get_input()"], [{"location": ` + calleeLocation + `, "content": "raw"}], ` +
+				`["CliLoc", [` + calleeLocation + `, "raw"]]]]`,
+			expected: SemgrepCallTrace{
+				Location:         expectedLocation,
+				Content:          "get_input()",
+				IntermediateVars: []SemgrepIntermediateVar{{Location: expectedCalleeLocation, Content: "raw"}},
+				Callee:           &SemgrepCallTrace{Location: expectedCalleeLocation, Content: "raw"},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var trace SemgrepCallTrace
+
+			err := json.Unmarshal([]byte(testCase.trace), &trace)
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, trace)
+		})
+	}
+}
+
+func TestSemgrepCallTraceUnmarshalJSONWithInvalidTrace(t *testing.T) {
+	testCases := map[string]string{
+		"unknown variant":   `["Unknown", {}]`,
+		"not a variant":     `{"path": "app.py"}`,
+		"missing arguments": `["CoreCall", [{}]]`,
+	}
+	for name, invalidTrace := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var trace SemgrepCallTrace
+
+			err := json.Unmarshal([]byte(invalidTrace), &trace)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDataflowTraceMessageSuffix(t *testing.T) {
+	testCases := []struct {
+		name           string
+		trace          *DataflowTrace
+		expectedSuffix string
+	}{
+		{
+			name:           "no trace",
+			trace:          nil,
+			expectedSuffix: "",
+		},
+		{
+			name: "source, intermediate variables and sink",
+			trace: &DataflowTrace{
+				Source:           &CallTrace{Location: traceLocation("app.py", 3, "request.args.get('id')")},
+				IntermediateVars: []TraceLocation{traceLocation("app.py", 3, "user_id"), traceLocation("app.py", 5, "query")},
+				Sink:             &CallTrace{Location: traceLocation("app.py", 9, "cursor.execute(query)")},
+			},
+			expectedSuffix: " Tainted data flows from `request.args.get('id')` (line 3) through `user_id` (line 3), `query` (line 5) to `cursor.execute(query)` (line 9).",
+		},
+		{
+			name: "source in another function and file",
+			trace: &DataflowTrace{
+				Source: &CallTrace{
+					Location: traceLocation("app.py", 4, "read_input()"),
+					Callee:   &CallTrace{Location: traceLocation("input.py", 12, "sys.stdin")},
+				},
+			},
+			expectedSuffix: " Tainted data flows from `sys.stdin` (input.py:12).",
+		},
+		{
+			name: "locations without code and long code",
+			trace: &DataflowTrace{
+				Source: &CallTrace{Location: traceLocation("app.py", 2, "")},
+				Sink:   &CallTrace{Location: traceLocation("app.py", 7, "os.system(\n    'convert ' + filename + ' output.png')")},
+			},
+			expectedSuffix: " Tainted data flows from line 2 to `os.system( 'convert ' + filename + '...` (line 7).",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			suffix := testCase.trace.messageSuffix("app.py")
+
+			assert.Equal(t, testCase.expectedSuffix, suffix)
+		})
+	}
+}
+
+func TestParseCommandOutputWithDataflowTrace(t *testing.T) {
+	// Arrange
+	patternDescriptions := []codacy.PatternDescription{}
+	commandOutput := `{"results": [{
+		"check_id": "python.sqli",
+		"path": "app.py",
+		"start": {"line": 9, "col": 1, "offset": 100},
+		"end": {"line": 9, "col": 22, "offset": 121},
+		"extra": {
+			"message": "Detected SQL injection. Use parameterized queries.",
+			"dataflow_trace": {
+				"taint_source": ["CliLoc", [{"path": "app.py", "start": {"line": 3, "col": 6, "offset": 20}, "end": {"line": 3, "col": 18, "offset": 32}}, "request.args"]],
+				"intermediate_vars": [{"location": {"path": "app.py", "start": {"line": 5, "col": 1, "offset": 50}, "end": {"line": 5, "col": 6, "offset": 55}}, "content": "query"}],
+				"taint_sink": ["CliLoc", [{"path": "app.py", "start": {"line": 9, "col": 1, "offset": 100}, "end": {"line": 9, "col": 22, "offset": 121}}, "cursor.execute(query)"]]
+			}
+		}
+	}], "errors": []}`
+
+	// Act
+	result, err := parseCommandOutput(&patternDescriptions, commandOutput)
+
+	// Assert
+	assert.NoError(t, err)
+	issue := result[0].(Issue)
+	assert.Equal(t, "Detected SQL injection. Tainted data flows from `request.args` (line 3) through `query` (line 5) to `cursor.execute(query)` (line 9).", issue.Message)
+	assert.Equal(t, &DataflowTrace{
+		Source: &CallTrace{Location: TraceLocation{
+			File:    "app.py",
+			Range:   Range{Start: Position{Line: 3, Col: 6, Offset: 20}, End: Position{Line: 3, Col: 18, Offset: 32}},
+			Content: "request.args",
+		}},
+		IntermediateVars: []TraceLocation{{
+			File:    "app.py",
+			Range:   Range{Start: Position{Line: 5, Col: 1, Offset: 50}, End: Position{Line: 5, Col: 6, Offset: 55}},
+			Content: "query",
+		}},
+		Sink: &CallTrace{Location: TraceLocation{
+			File:    "app.py",
+			Range:   Range{Start: Position{Line: 9, Col: 1, Offset: 100}, End: Position{Line: 9, Col: 22, Offset: 121}},
+			Content: "cursor.execute(query)",
+		}},
+	}, issue.Trace)
+}
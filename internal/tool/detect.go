@@ -0,0 +1,234 @@
+package tool
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// contentDetectionBytes is how much of a file is read to detect its language from its content.
+	contentDetectionBytes = 1024
+	// modelineLines is the number of lines at the start of a file where modelines are looked for.
+	modelineLines = 5
+)
+
+// fileNameToLanguageMap are the languages of well-known file names.
+// Jenkinsfiles and Makefiles have no language: they aren't analysed as generic, since the generic rules
+// for a language, like the SQL ones, would report false positives, and the agnostic rules already run on them.
+var fileNameToLanguageMap = map[string]string{
+	"Dockerfile":    "dockerfile",
+	"Containerfile": "dockerfile",
+	"Jenkinsfile":   "none",
+	"Makefile":      "none",
+	"makefile":      "none",
+	"GNUmakefile":   "none",
+	"Gemfile":       "ruby",
+	"Rakefile":      "ruby",
+	"Vagrantfile":   "ruby",
+	"Podfile":       "ruby",
+	"Fastfile":      "ruby",
+	"Guardfile":     "ruby",
+	"Brewfile":      "ruby",
+}
+
+// additionalLanguagesMap are the languages, besides the one of their extension, files are also analysed with
+// because their content can be written in more than one language.
+// YAML files aren't analysed as generic: the agnostic rules already run on them,
+// and the generic rules for a language, like the SQL ones, would report false positives.
+var additionalLanguagesMap = map[string][]string{
+	".h": {"cpp"},
+}
+
+// fileNamePrefixToLanguageMap are the languages of well-known file names with a variant suffix, like Dockerfile.prod.
+var fileNamePrefixToLanguageMap = map[string]string{
+	"Dockerfile.":    "dockerfile",
+	"Containerfile.": "dockerfile",
+	"Jenkinsfile.":   "none",
+}
+
+// interpreterToLanguageMap are the languages of the interpreters of shebangs and of the modes of modelines.
+// Version suffixes, like in python3.11, are removed before looking them up.
+var interpreterToLanguageMap = map[string]string{
+	"python":       "python",
+	"pypy":         "python",
+	"bash":         "bash",
+	"sh":           "sh",
+	"dash":         "sh",
+	"ksh":          "sh",
+	"zsh":          "bash",
+	"shell-script": "sh",
+	"node":         "javascript",
+	"nodejs":       "javascript",
+	"js":           "javascript",
+	"js2":          "javascript",
+	"javascript":   "javascript",
+	"deno":         "typescript",
+	"ts-node":      "typescript",
+	"typescript":   "typescript",
+	"ruby":         "ruby",
+	"jruby":        "ruby",
+	"php":          "php",
+	"lua":          "lua",
+	"rscript":      "r",
+	"r":            "r",
+	"julia":        "julia",
+	"elixir":       "elixir",
+	"scala":        "scala",
+	"kotlin":       "kotlin",
+	"swift":        "swift",
+	"go":           "go",
+	"java":         "java",
+	"c":            "c",
+	"c++":          "cpp",
+	"cpp":          "cpp",
+	"clojure":      "clojure",
+	"bb":           "clojure",
+	"sbcl":         "lisp",
+	"lisp":         "lisp",
+	"dockerfile":   "dockerfile",
+	"yaml":         "yaml",
+	"json":         "json",
+	"xml":          "xml",
+	"html":         "html",
+	"sql":          "generic",
+}
+
+var (
+	emacsModelineRegex  = regexp.MustCompile(`-\*-\s*(.*?)\s*-\*-`)
+	vimModelineRegex    = regexp.MustCompile(`(?:^|\s)(?:vi|vim|ex):.*?\b(?:ft|filetype|syntax)=([\w+-]+)`)
+	versionSuffixRegex  = regexp.MustCompile(`[\d.]+$`)
+	environmentVarRegex = regexp.MustCompile(`^\w+=`)
+)
+
+// detectLanguage detects the language of a file from its name: its extension or a well-known file name.
+func detectLanguage(fileName string) string {
+	baseName := filepath.Base(fileName)
+	if language, ok := extensionToLanguageMap[strings.ToLower(filepath.Ext(baseName))]; ok {
+		return language
+	}
+	if language, ok := fileNameToLanguageMap[baseName]; ok {
+		return language
+	}
+	for prefix, language := range fileNamePrefixToLanguageMap {
+		if strings.HasPrefix(baseName, prefix) {
+			return language
+		}
+	}
+	return "none"
+}
+
+// detectFileLanguages returns every language a file of the plan is analysed with.
+func (p *analysisPlan) detectFileLanguages(file string) []string {
+	additionalLanguages := additionalLanguagesMap[strings.ToLower(filepath.Ext(file))]
+	return append([]string{p.detectFileLanguage(file)}, additionalLanguages...)
+}
+
+// detectFileLanguage detects the language of a file of the plan from its name and,
+// when the name isn't enough, from the shebang or modeline at the start of the file.
+func (p *analysisPlan) detectFileLanguage(file string) string {
+	language := detectLanguage(file)
+	if language != "none" {
+		return language
+	}
+	if contentLanguage, ok := detectContentLanguage(p.absolutePath(file)); ok {
+		return contentLanguage
+	}
+	return language
+}
+
+// detectContentLanguage detects the language of a file from the start of its content.
+// Unreadable and binary files have no language.
+func detectContentLanguage(fileName string) (string, bool) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	content := make([]byte, contentDetectionBytes)
+	n, err := io.ReadFull(file, content)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", false
+	}
+	return detectLanguageFromContent(content[:n])
+}
+
+func detectLanguageFromContent(content []byte) (string, bool) {
+	if bytes.IndexByte(content, 0) >= 0 {
+		return "", false
+	}
+
+	lines := strings.SplitN(string(content), "\n", modelineLines+1)
+	lines = lines[:min(len(lines), modelineLines)]
+
+	if interpreter, ok := shebangInterpreter(lines[0]); ok {
+		if language, ok := languageOfName(interpreter); ok {
+			return language, true
+		}
+	}
+	for _, line := range lines {
+		if mode, ok := modelineMode(line); ok {
+			if language, ok := languageOfName(mode); ok {
+				return language, true
+			}
+		}
+	}
+	return "", false
+}
+
+// shebangInterpreter returns the interpreter of a shebang line, like python3 in `#!/usr/bin/env python3`.
+func shebangInterpreter(line string) (string, bool) {
+	command, ok := strings.CutPrefix(strings.TrimSpace(line), "#!")
+	if !ok {
+		return "", false
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter != "env" {
+		return interpreter, true
+	}
+	// env can have options, like -S, and variable assignments before the interpreter
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(field, "-") && !environmentVarRegex.MatchString(field) {
+			return filepath.Base(field), true
+		}
+	}
+	return "", false
+}
+
+// modelineMode returns the mode of an Emacs modeline, like `-*- mode: python -*-`,
+// or the file type of a vim modeline, like `vim: set ft=python:`.
+func modelineMode(line string) (string, bool) {
+	if match := emacsModelineRegex.FindStringSubmatch(line); match != nil {
+		if !strings.Contains(match[1], ":") {
+			return match[1], true
+		}
+		for _, variable := range strings.Split(match[1], ";") {
+			name, value, _ := strings.Cut(variable, ":")
+			if strings.EqualFold(strings.TrimSpace(name), "mode") {
+				return strings.TrimSpace(value), true
+			}
+		}
+	}
+	if match := vimModelineRegex.FindStringSubmatch(line); match != nil {
+		return match[1], true
+	}
+	return "", false
+}
+
+func languageOfName(name string) (string, bool) {
+	name = strings.TrimSuffix(strings.ToLower(name), "-mode")
+	if language, ok := interpreterToLanguageMap[name]; ok {
+		return language, true
+	}
+	language, ok := interpreterToLanguageMap[versionSuffixRegex.ReplaceAllString(name, "")]
+	return language, ok
+}
@@ -0,0 +1,89 @@
+package tool
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectLanguageWithWellKnownFileNames(t *testing.T) {
+	testCases := map[string]string{
+		"Dockerfile":             "dockerfile",
+		"deploy/Dockerfile":      "dockerfile",
+		"deploy/Dockerfile.prod": "dockerfile",
+		"deploy/app.Dockerfile":  "dockerfile",
+		"Containerfile":          "dockerfile",
+		"ci/Jenkinsfile":         "none",
+		"ci/Jenkinsfile.release": "none",
+		"Makefile":               "none",
+		"GNUmakefile":            "none",
+		"Gemfile":                "ruby",
+		"Rakefile":               "ruby",
+		"dockerfile.md":          "none",
+		"src/file":               "none",
+	}
+	for fileName, expectedLanguage := range testCases {
+		t.Run(fileName, func(t *testing.T) {
+			assert.Equal(t, expectedLanguage, detectLanguage(fileName))
+		})
+	}
+}
+
+func TestDetectLanguageFromContent(t *testing.T) {
+	testCases := []struct {
+		name             string
+		content          string
+		expectedLanguage string
+		expectedOk       bool
+	}{
+		{name: "env shebang", content: "#!/usr/bin/env python3\nprint(1)\n", expectedLanguage: "python", expectedOk: true},
+		{name: "env shebang with options", content: "#!/usr/bin/env -S PYTHONPATH=. python3.11 -u\n", expectedLanguage: "python", expectedOk: true},
+		{name: "absolute shebang", content: "#!/bin/bash\necho 1\n", expectedLanguage: "bash", expectedOk: true},
+		{name: "shebang with space", content: "#! /bin/sh -e\n", expectedLanguage: "sh", expectedOk: true},
+		{name: "node shebang", content: "#!/usr/bin/env node\n", expectedLanguage: "javascript", expectedOk: true},
+		{name: "ruby shebang with version", content: "#!/usr/local/bin/ruby2.7 -w\n", expectedLanguage: "ruby", expectedOk: true},
+		{name: "emacs modeline", content: "# -*- mode: ruby; coding: utf-8 -*-\n", expectedLanguage: "ruby", expectedOk: true},
+		{name: "emacs modeline without variables", content: "// -*- C++ -*-\n", expectedLanguage: "cpp", expectedOk: true},
+		{name: "emacs modeline after unknown shebang", content: "#!/usr/bin/perl\n# -*- mode: python-mode -*-\n", expectedLanguage: "python", expectedOk: true},
+		{name: "vim modeline", content: "\n\n# vim: set ft=sh ts=2:\n", expectedLanguage: "sh", expectedOk: true},
+		{name: "vim modeline with filetype", content: "// vi: filetype=javascript\n", expectedLanguage: "javascript", expectedOk: true},
+		{name: "modeline after the first lines", content: "1\n2\n3\n4\n5\n# vim: ft=python\n"},
+		{name: "unsupported interpreter", content: "#!/usr/bin/perl\nprint 1;\n"},
+		{name: "no shebang", content: "hello\n"},
+		{name: "binary", content: "#!/bin/sh\n\x00\x01"},
+		{name: "empty", content: ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			language, ok := detectLanguageFromContent([]byte(testCase.content))
+
+			assert.Equal(t, testCase.expectedOk, ok)
+			assert.Equal(t, testCase.expectedLanguage, language)
+		})
+	}
+}
+
+func TestAnalysisPlanDetectFileLanguage(t *testing.T) {
+	// Arrange
+	sourceDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(path.Join(sourceDir, "bin"), 0700))
+	assert.NoError(t, os.WriteFile(path.Join(sourceDir, "bin", "deploy"), []byte("#!/usr/bin/env python3\n"), 0700))
+	assert.NoError(t, os.WriteFile(path.Join(sourceDir, "bin", "notes"), []byte("deploy notes\n"), 0600))
+	assert.NoError(t, os.WriteFile(path.Join(sourceDir, "main.go"), []byte("#!/bin/bash\n"), 0600))
+	plan := newAnalysisPlan(sourceDir)
+
+	// Act
+	plan.addFileToFilesByLanguage("bin/deploy")
+	plan.addFileToFilesByLanguage("bin/notes")
+	plan.addFileToFilesByLanguage("main.go")
+	plan.addFileToFilesByLanguage("bin/missing")
+
+	// Assert
+	assert.Equal(t, map[string][]string{
+		"python": {"bin/deploy"},
+		"none":   {"bin/notes", "bin/missing"},
+		"go":     {"main.go"},
+	}, plan.filesByLanguage())
+}
@@ -0,0 +1,133 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path/filepath"
+	"strconv"
+)
+
+// diffContextLines is the number of unchanged lines around the changes of a diff, like git diff.
+const diffContextLines = 3
+
+// diffRegion is a change of whole lines, made by one or more fixes.
+// Lines are indexes of the lines of the file, from (included) to (excluded).
+type diffRegion struct {
+	from     int
+	to       int
+	newLines [][]byte
+}
+
+// writeUnifiedDiff writes the changes of sorted, non-overlapping fixes to a file in the unified diff format.
+func writeUnifiedDiff(w io.Writer, file string, content []byte, fixes []Issue) error {
+	lines := splitLines(content)
+	lineOffsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		lineOffsets[i+1] = lineOffsets[i] + len(line)
+	}
+	regions := diffRegions(content, lines, lineOffsets, fixes)
+	if len(regions) == 0 {
+		return nil
+	}
+
+	path := filepath.ToSlash(file)
+	out := bufio.NewWriter(w)
+	out.WriteString("diff --git a/" + path + " b/" + path + "\n")
+	out.WriteString("--- a/" + path + "\n")
+	out.WriteString("+++ b/" + path + "\n")
+
+	// Line count difference between the fixed and the original file before the current hunk
+	delta := 0
+	for len(regions) > 0 {
+		// A hunk has the regions whose context lines touch
+		hunkSize := 1
+		for hunkSize < len(regions) && regions[hunkSize].from-regions[hunkSize-1].to <= 2*diffContextLines {
+			hunkSize++
+		}
+		hunk := regions[:hunkSize]
+		regions = regions[hunkSize:]
+
+		from := max(0, hunk[0].from-diffContextLines)
+		to := min(len(lines), hunk[len(hunk)-1].to+diffContextLines)
+		oldCount := to - from
+		newCount := oldCount
+		for _, region := range hunk {
+			newCount += len(region.newLines) - (region.to - region.from)
+		}
+		out.WriteString("@@ -" + hunkRange(from, oldCount) + " +" + hunkRange(from+delta, newCount) + " @@\n")
+		delta += newCount - oldCount
+
+		line := from
+		for _, region := range hunk {
+			writeDiffLines(out, ' ', lines[line:region.from])
+			writeDiffLines(out, '-', lines[region.from:region.to])
+			writeDiffLines(out, '+', region.newLines)
+			line = region.to
+		}
+		writeDiffLines(out, ' ', lines[line:to])
+	}
+	return out.Flush()
+}
+
+// diffRegions returns the lines changed by the fixes, merging the fixes that change the same lines.
+func diffRegions(content []byte, lines [][]byte, lineOffsets []int, fixes []Issue) []diffRegion {
+	type regionFixes struct {
+		from, to int
+		fixes    []Issue
+	}
+	var merged []regionFixes
+	for _, fix := range fixes {
+		start, end := fix.Range.Start.Offset, fix.Range.End.Offset
+		// A fix changes the lines from the one it starts in to the one of its last character
+		from := lineOfOffset(content, start) - 1
+		to := min(len(lines), lineOfOffset(content, max(start, end-1)))
+		if last := len(merged) - 1; last >= 0 && from < merged[last].to {
+			merged[last].to = max(merged[last].to, to)
+			merged[last].fixes = append(merged[last].fixes, fix)
+			continue
+		}
+		merged = append(merged, regionFixes{from: from, to: to, fixes: []Issue{fix}})
+	}
+
+	regions := make([]diffRegion, 0, len(merged))
+	for _, region := range merged {
+		offset := lineOffsets[region.from]
+		newContent := applyFixes(content[offset:lineOffsets[region.to]], offset, region.fixes)
+		regions = append(regions, diffRegion{from: region.from, to: region.to, newLines: splitLines(newContent)})
+	}
+	return regions
+}
+
+// hunkRange formats the start and line count of a hunk, given the index of its first line.
+// Like diff, an empty range starts at the line before it.
+func hunkRange(from, count int) string {
+	start := from + 1
+	if count == 0 {
+		start = from
+	}
+	return strconv.Itoa(start) + "," + strconv.Itoa(count)
+}
+
+func writeDiffLines(out *bufio.Writer, prefix byte, lines [][]byte) {
+	for _, line := range lines {
+		out.WriteByte(prefix)
+		out.Write(line)
+		if !bytes.HasSuffix(line, []byte{'\n'}) {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// splitLines splits the content in lines, keeping their line endings.
+func splitLines(content []byte) [][]byte {
+	return bytes.SplitAfter(content, []byte{'\n'})[:bytes.Count(content, []byte{'\n'})+lastLineCount(content)]
+}
+
+// lastLineCount is 1 when the content has a last line without line ending.
+func lastLineCount(content []byte) int {
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		return 1
+	}
+	return 0
+}
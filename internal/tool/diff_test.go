@@ -0,0 +1,103 @@
+package tool
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// numberedLines returns a content of count lines, line1 to lineN.
+func numberedLines(count int) string {
+	var content strings.Builder
+	for i := 1; i <= count; i++ {
+		fmt.Fprintf(&content, "line%d\n", i)
+	}
+	return content.String()
+}
+
+func TestWriteUnifiedDiff(t *testing.T) {
+	testCases := []struct {
+		name         string
+		content      string
+		fixes        func(content string) []Issue
+		expectedDiff string
+	}{
+		{
+			name:    "change in the middle of the file",
+			content: numberedLines(10),
+			fixes: func(content string) []Issue {
+				return []Issue{fixIssue("rule", "a.py", content, "line5", "fixed5")}
+			},
+			expectedDiff: "diff --git a/a.py b/a.py\n--- a/a.py\n+++ b/a.py\n" +
+				"@@ -2,7 +2,7 @@\n line2\n line3\n line4\n-line5\n+fixed5\n line6\n line7\n line8\n",
+		},
+		{
+			name:    "changes with touching context in one hunk",
+			content: numberedLines(12),
+			fixes: func(content string) []Issue {
+				return []Issue{
+					fixIssue("rule", "a.py", content, "line2\n", ""),
+					fixIssue("rule", "a.py", content, "line8", "fixed8\nadded8"),
+				}
+			},
+			expectedDiff: "diff --git a/a.py b/a.py\n--- a/a.py\n+++ b/a.py\n" +
+				"@@ -1,11 +1,11 @@\n line1\n-line2\n line3\n line4\n line5\n line6\n line7\n-line8\n+fixed8\n+added8\n line9\n line10\n line11\n",
+		},
+		{
+			name:    "changes in separate hunks",
+			content: numberedLines(20),
+			fixes: func(content string) []Issue {
+				return []Issue{
+					fixIssue("rule", "a.py", content, "line2\n", "line2\nadded2\n"),
+					fixIssue("rule", "a.py", content, "line15", "fixed15"),
+				}
+			},
+			expectedDiff: "diff --git a/a.py b/a.py\n--- a/a.py\n+++ b/a.py\n" +
+				"@@ -1,5 +1,6 @@\n line1\n-line2\n+line2\n+added2\n line3\n line4\n line5\n" +
+				"@@ -12,7 +13,7 @@\n line12\n line13\n line14\n-line15\n+fixed15\n line16\n line17\n line18\n",
+		},
+		{
+			name:    "change of the last line without line ending",
+			content: "x = 1\nexit(1)",
+			fixes: func(content string) []Issue {
+				return []Issue{fixIssue("rule", "a.py", content, "exit(1)", "sys.exit(1)")}
+			},
+			expectedDiff: "diff --git a/a.py b/a.py\n--- a/a.py\n+++ b/a.py\n" +
+				"@@ -1,2 +1,2 @@\n x = 1\n-exit(1)\n\\ No newline at end of file\n+sys.exit(1)\n\\ No newline at end of file\n",
+		},
+		{
+			name:    "insertion at the end of the file",
+			content: "x = 1\n",
+			fixes: func(content string) []Issue {
+				end := Position{Line: 2, Col: 1, Offset: len(content)}
+				fix := fixIssue("rule", "a.py", content, "", "y = 2\n")
+				fix.Range = Range{Start: end, End: end}
+				return []Issue{fix}
+			},
+			expectedDiff: "diff --git a/a.py b/a.py\n--- a/a.py\n+++ b/a.py\n" +
+				"@@ -1,1 +1,2 @@\n x = 1\n+y = 2\n",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var diff bytes.Buffer
+
+			err := writeUnifiedDiff(&diff, "a.py", []byte(testCase.content), testCase.fixes(testCase.content))
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedDiff, diff.String())
+		})
+	}
+}
+
+func TestWriteUnifiedDiffWithoutFixes(t *testing.T) {
+	var diff bytes.Buffer
+
+	err := writeUnifiedDiff(&diff, "a.py", []byte("x = 1\n"), nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, diff.String())
+}
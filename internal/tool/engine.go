@@ -0,0 +1,50 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// proEngineFlags are the flags of semgrep-core-proprietary that the semgrep-core of the open source engine doesn't have.
+var proEngineFlags = []string{"-deep_intra_file", "-secrets"}
+
+// SemgrepEngine is the semgrep-core binary the tool runs.
+type SemgrepEngine struct {
+	// Binary is a name in the PATH or a path.
+	Binary string
+	// Pro is whether the binary is semgrep-core-proprietary, with the flags of the Pro engine.
+	// Without them, semgrep misses the issues of the secrets rules and of the Pro dataflow analysis.
+	Pro bool
+}
+
+// ProbeSemgrepEngine checks that a binary is semgrep-core, from the flags listed by its -help,
+// and finds out if it has the flags of the Pro engine.
+// The semgrep command installed by pip or brew isn't semgrep-core: it runs semgrep-core with other flags.
+func ProbeSemgrepEngine(ctx context.Context, binary string) (SemgrepEngine, error) {
+	// The help of semgrep-core ends with an error status on some versions, so only its output matters
+	output, err := exec.CommandContext(ctx, binary, "-help").CombinedOutput()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return SemgrepEngine{}, fmt.Errorf("failed to run semgrep-core: %s\n%w", binary, err)
+	}
+
+	flags := strings.Fields(string(output))
+	if !lo.Contains(flags, "-rules") {
+		return SemgrepEngine{}, fmt.Errorf("%s is not semgrep-core: its -help doesn't list the -rules flag", binary)
+	}
+	return SemgrepEngine{Binary: binary, Pro: lo.Every(flags, proEngineFlags)}, nil
+}
+
+// cacheVersion is the version the results of the engine are cached with:
+// the same version of semgrep reports other issues without the Pro engine.
+func (e SemgrepEngine) cacheVersion(toolVersion string) string {
+	if e.Pro {
+		return toolVersion
+	}
+	return toolVersion + "-oss"
+}
@@ -0,0 +1,70 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFakeBinary writes a script that prints a help and ends with a status.
+func writeFakeBinary(t *testing.T, help string, status int) string {
+	binary := filepath.Join(t.TempDir(), "semgrep-core")
+	script := "#!/bin/sh\ncat <<'EOF'\n" + help + "\nEOF\nexit " + string(rune('0'+status)) + "\n"
+	assert.NoError(t, os.WriteFile(binary, []byte(script), 0o755))
+	return binary
+}
+
+func TestProbeSemgrepEngine(t *testing.T) {
+	testCases := []struct {
+		name          string
+		help          string
+		status        int
+		expectedPro   bool
+		expectedError string
+	}{
+		{
+			name:        "semgrep-core-proprietary",
+			help:        "Usage: semgrep-core [options]\n  -rules <file> rules\n  -deep_intra_file pro\n  -secrets validate secrets\n  -help  Display this list of options",
+			expectedPro: true,
+		},
+		{
+			name:   "semgrep-core",
+			help:   "Usage: semgrep-core [options]\n  -rules <file> rules\n  -secrets_timeout <int> unrelated\n  -help  Display this list of options",
+			status: 2,
+		},
+		{
+			name:          "semgrep command of pip",
+			help:          "Usage: semgrep [OPTIONS] COMMAND [ARGS]...\n  scan  Run semgrep rules on files",
+			status:        2,
+			expectedError: "is not semgrep-core",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			binary := writeFakeBinary(t, testCase.help, testCase.status)
+
+			engine, err := ProbeSemgrepEngine(context.Background(), binary)
+
+			if testCase.expectedError != "" {
+				assert.ErrorContains(t, err, testCase.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, SemgrepEngine{Binary: binary, Pro: testCase.expectedPro}, engine)
+		})
+	}
+}
+
+func TestProbeSemgrepEngineWithMissingBinary(t *testing.T) {
+	_, err := ProbeSemgrepEngine(context.Background(), filepath.Join(t.TempDir(), "semgrep-core"))
+
+	assert.ErrorContains(t, err, "failed to run semgrep-core")
+}
+
+func TestSemgrepEngineCacheVersion(t *testing.T) {
+	assert.Equal(t, "1.78.0", SemgrepEngine{Pro: true}.cacheVersion("1.78.0"))
+	assert.Equal(t, "1.78.0-oss", SemgrepEngine{}.cacheVersion("1.78.0"))
+}
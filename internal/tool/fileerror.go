@@ -0,0 +1,102 @@
+package tool
+
+import (
+	"encoding/json"
+	"strings"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+)
+
+const (
+	// maxFileErrorMessageLength is the maximum length of the semgrep message in a file error.
+	maxFileErrorMessageLength = 250
+	// maxFileErrorsPerFile is the maximum number of errors reported for a single file.
+	maxFileErrorsPerFile = 5
+)
+
+// SemgrepErrorType is the type of a semgrep error.
+// Semgrep reports it either as a name or as an array starting with the name followed by details.
+type SemgrepErrorType string
+
+func (t *SemgrepErrorType) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = SemgrepErrorType(name)
+		return nil
+	}
+
+	var nameWithDetails []json.RawMessage
+	if err := json.Unmarshal(data, &nameWithDetails); err != nil {
+		return err
+	}
+	if len(nameWithDetails) == 0 {
+		*t = ""
+		return nil
+	}
+	if err := json.Unmarshal(nameWithDetails[0], &name); err != nil {
+		return err
+	}
+	*t = SemgrepErrorType(name)
+	return nil
+}
+
+// fileErrorKind is the reason a file was not fully analysed.
+type fileErrorKind string
+
+const (
+	syntaxError         fileErrorKind = "Syntax error"
+	ruleTimeout         fileErrorKind = "Rule timeout"
+	memoryLimit         fileErrorKind = "Memory limit exceeded"
+	unsupportedLanguage fileErrorKind = "Unsupported language"
+	internalError       fileErrorKind = "Internal error"
+	analysisTimeout     fileErrorKind = "Analysis timeout"
+)
+
+// https://github.com/semgrep/semgrep-interfaces/blob/main/semgrep_output_v1.atd (error_type)
+var fileErrorKindBySemgrepErrorType = map[SemgrepErrorType]fileErrorKind{
+	"Lexical error":                     syntaxError,
+	"Syntax error":                      syntaxError,
+	"Other syntax error":                syntaxError,
+	"AST builder error":                 syntaxError,
+	"PartialParsing":                    syntaxError,
+	"Timeout":                           ruleTimeout,
+	"Timeout during interfile analysis": ruleTimeout,
+	"Out of memory":                     memoryLimit,
+	"Out of memory during interfile analysis": memoryLimit,
+	"Stack overflow":   memoryLimit,
+	"Unknown language": unsupportedLanguage,
+	"Missing plugin":   unsupportedLanguage,
+}
+
+func classifySemgrepError(semgrepError SemgrepError) fileErrorKind {
+	if kind, ok := fileErrorKindBySemgrepErrorType[semgrepError.Type]; ok {
+		return kind
+	}
+	return internalError
+}
+
+func newFileErrorMessage(kind fileErrorKind, semgrepMessage string) string {
+	return string(kind) + ": " + lo.Substring(strings.TrimSpace(semgrepMessage), 0, maxFileErrorMessageLength)
+}
+
+// limitFileErrors removes repeated errors of the same file and keeps at most maxFileErrorsPerFile
+// errors for each file, so a single broken file can't flood the results.
+// Issues and the order of the results are kept.
+func limitFileErrors(results []codacy.Result) []codacy.Result {
+	seenErrors := map[codacy.FileError]bool{}
+	errorsByFile := map[string]int{}
+
+	return lo.Filter(results, func(result codacy.Result, _ int) bool {
+		fileError, ok := result.(codacy.FileError)
+		if !ok {
+			return true
+		}
+		if seenErrors[fileError] || errorsByFile[fileError.File] >= maxFileErrorsPerFile {
+			return false
+		}
+		seenErrors[fileError] = true
+		errorsByFile[fileError.File]++
+		return true
+	})
+}
@@ -0,0 +1,112 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemgrepErrorTypeUnmarshal(t *testing.T) {
+	tests := []struct {
+		test_name string
+		input     string
+		expected  SemgrepErrorType
+	}{
+		{
+			test_name: "name",
+			input:     `"Syntax error"`,
+			expected:  "Syntax error",
+		},
+		{
+			test_name: "name with details",
+			input:     `["PartialParsing", [{"path": "file.py"}]]`,
+			expected:  "PartialParsing",
+		},
+		{
+			test_name: "empty array",
+			input:     `[]`,
+			expected:  "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.test_name, func(t *testing.T) {
+			var errorType SemgrepErrorType
+			err := json.Unmarshal([]byte(test.input), &errorType)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, errorType)
+		})
+	}
+}
+
+func TestClassifySemgrepError(t *testing.T) {
+	tests := []struct {
+		errorType SemgrepErrorType
+		expected  fileErrorKind
+	}{
+		{errorType: "Syntax error", expected: syntaxError},
+		{errorType: "PartialParsing", expected: syntaxError},
+		{errorType: "Timeout", expected: ruleTimeout},
+		{errorType: "Out of memory", expected: memoryLimit},
+		{errorType: "Unknown language", expected: unsupportedLanguage},
+		{errorType: "Fatal error", expected: internalError},
+		{errorType: "", expected: internalError},
+	}
+	for _, test := range tests {
+		t.Run(string(test.errorType), func(t *testing.T) {
+			assert.Equal(t, test.expected, classifySemgrepError(SemgrepError{Type: test.errorType}))
+		})
+	}
+}
+
+func TestParseCommandOutputWithErrors(t *testing.T) {
+	// Arrange
+	commandOutput := `{"results": [], "errors": [
+		{"error_type": "Syntax error", "severity": "error", "message": "unexpected token", "location": {"path": "broken.py"}},
+		{"error_type": ["PartialParsing", []], "severity": "warn", "message": "partially parsed", "location": {"path": "partial.py"}},
+		{"error_type": "Rule parse error", "severity": "error", "message": "invalid rule"}
+	]}`
+
+	// Act
+	result, err := parseCommandOutput(&[]codacy.PatternDescription{}, commandOutput)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []codacy.Result{
+		codacy.FileError{File: "broken.py", Message: "Syntax error: unexpected token"},
+		codacy.FileError{File: "partial.py", Message: "Syntax error: partially parsed"},
+	}, result)
+}
+
+func TestNewFileErrorMessageTruncatesMessage(t *testing.T) {
+	// Act
+	message := newFileErrorMessage(ruleTimeout, strings.Repeat("a", 1000))
+
+	// Assert
+	assert.Equal(t, "Rule timeout: "+strings.Repeat("a", maxFileErrorMessageLength), message)
+}
+
+func TestLimitFileErrors(t *testing.T) {
+	// Arrange
+	results := []codacy.Result{
+		codacy.FileError{File: "a.py", Message: "Syntax error: unexpected token"},
+		codacy.Issue{PatternID: "pattern_1", File: "a.py", Line: 1},
+		codacy.FileError{File: "a.py", Message: "Syntax error: unexpected token"},
+		codacy.FileError{File: "b.py", Message: "Rule timeout: rule_1"},
+	}
+	for i := range maxFileErrorsPerFile + 2 {
+		results = append(results, codacy.FileError{File: "c.py", Message: fmt.Sprintf("Rule timeout: rule_%d", i)})
+	}
+
+	// Act
+	limited := limitFileErrors(results)
+
+	// Assert
+	assert.Equal(t, results[:2], limited[:2])
+	assert.Equal(t, results[3], limited[2])
+	assert.Len(t, limited, 3+maxFileErrorsPerFile)
+}
@@ -0,0 +1,71 @@
+package tool
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+)
+
+// fingerprintIssues sets the fingerprint of the issues, from their rule, their file and the code they matched.
+// The code is normalized so changes of whitespace and line breaks, or lines added around it, keep the fingerprint.
+// Issues of a rule that match the same code in a file are told apart by their order in the file.
+func (p *analysisPlan) fingerprintIssues(results []codacy.Result) []codacy.Result {
+	fingerprinted := make([]codacy.Result, len(results))
+	copy(fingerprinted, results)
+
+	// Indexes of the issues with the same rule, file and code
+	occurrences := map[string][]int{}
+	var keys []string
+	for i, result := range fingerprinted {
+		issue, ok := result.(Issue)
+		if !ok {
+			continue
+		}
+		key := fingerprintKey(issue, p.fileContent(issue.File))
+		if _, found := occurrences[key]; !found {
+			keys = append(keys, key)
+		}
+		occurrences[key] = append(occurrences[key], i)
+	}
+
+	for _, key := range keys {
+		indexes := occurrences[key]
+		sort.SliceStable(indexes, func(i, j int) bool {
+			a, b := fingerprinted[indexes[i]].(Issue), fingerprinted[indexes[j]].(Issue)
+			if a.Range.Start.Offset != b.Range.Start.Offset {
+				return a.Range.Start.Offset < b.Range.Start.Offset
+			}
+			return a.Line < b.Line
+		})
+		for occurrence, index := range indexes {
+			issue := fingerprinted[index].(Issue)
+			issue.Fingerprint = fingerprint(key, occurrence)
+			fingerprinted[index] = issue
+		}
+	}
+	return fingerprinted
+}
+
+// fingerprintKey identifies the rule, file and normalized code of an issue.
+// When the range of the issue doesn't match the content of its file, there is no code.
+func fingerprintKey(issue Issue, content []byte) string {
+	code := ""
+	if rangeMatchesContent(content, issue.Range) {
+		code = normalizeCode(content[issue.Range.Start.Offset:issue.Range.End.Offset])
+	}
+	return issue.PatternID + "\x00" + issue.File + "\x00" + code
+}
+
+// normalizeCode removes all the whitespace of the code.
+func normalizeCode(code []byte) string {
+	return strings.Join(strings.Fields(string(code)), "")
+}
+
+func fingerprint(key string, occurrence int) string {
+	hash := sha256.Sum256([]byte(key + "\x00" + strconv.Itoa(occurrence)))
+	return hex.EncodeToString(hash[:])
+}
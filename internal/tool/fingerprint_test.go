@@ -0,0 +1,81 @@
+package tool
+
+import (
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+// fingerprintOf returns the fingerprint of an issue of a rule matching the code in a file with the content.
+func fingerprintOf(t *testing.T, patternID, file, content, matched string) string {
+	sourceDir := writeSourceFiles(t, map[string]string{file: content})
+	plan := newAnalysisPlan(sourceDir)
+
+	results := plan.fingerprintIssues([]codacy.Result{fixIssue(patternID, file, content, matched, "")})
+
+	return results[0].(Issue).Fingerprint
+}
+
+func TestFingerprintIssuesIgnoresMovesAndWhitespace(t *testing.T) {
+	// Arrange
+	original := fingerprintOf(t, "python.eval", "app.py", "x = eval(data, {})\n", "eval(data, {})")
+
+	// Act
+	moved := fingerprintOf(t, "python.eval", "app.py", "import os\n\n\nx = eval(data, {})\n", "eval(data, {})")
+	reformatted := fingerprintOf(t, "python.eval", "app.py", "x = eval(\n    data,\n    {}\n)\n", "eval(\n    data,\n    {}\n)")
+
+	// Assert
+	assert.Len(t, original, 64)
+	assert.Equal(t, original, moved)
+	assert.Equal(t, original, reformatted)
+}
+
+func TestFingerprintIssuesChangesWithRuleFileAndCode(t *testing.T) {
+	// Arrange
+	original := fingerprintOf(t, "python.eval", "app.py", "x = eval(data)\n", "eval(data)")
+
+	// Act
+	otherRule := fingerprintOf(t, "python.exec", "app.py", "x = eval(data)\n", "eval(data)")
+	otherFile := fingerprintOf(t, "python.eval", "main.py", "x = eval(data)\n", "eval(data)")
+	otherCode := fingerprintOf(t, "python.eval", "app.py", "x = eval(other)\n", "eval(other)")
+
+	// Assert
+	assert.NotEqual(t, original, otherRule)
+	assert.NotEqual(t, original, otherFile)
+	assert.NotEqual(t, original, otherCode)
+}
+
+func TestFingerprintIssuesWithOccurrencesOfTheSameCode(t *testing.T) {
+	// Arrange
+	content := "eval(data)\nprint(1)\neval(data)\n"
+	sourceDir := writeSourceFiles(t, map[string]string{"app.py": content})
+	first := fixIssue("python.eval", "app.py", content, "eval(data)", "")
+	second := first
+	second.Line = 3
+	second.Range = Range{Start: Position{Line: 3, Col: 1, Offset: 20}, End: Position{Line: 3, Col: 11, Offset: 30}}
+	fileError := codacy.FileError{File: "app.py", Message: "Syntax error: message"}
+
+	// Act
+	inOrder := newAnalysisPlan(sourceDir).fingerprintIssues([]codacy.Result{first, fileError, second})
+	reversed := newAnalysisPlan(sourceDir).fingerprintIssues([]codacy.Result{second, first})
+
+	// Assert
+	firstFingerprint, secondFingerprint := inOrder[0].(Issue).Fingerprint, inOrder[2].(Issue).Fingerprint
+	assert.NotEqual(t, firstFingerprint, secondFingerprint)
+	assert.Equal(t, fileError, inOrder[1])
+	assert.Equal(t, secondFingerprint, reversed[0].(Issue).Fingerprint)
+	assert.Equal(t, firstFingerprint, reversed[1].(Issue).Fingerprint)
+}
+
+func TestFingerprintIssuesWithMissingFile(t *testing.T) {
+	// Arrange
+	plan := newAnalysisPlan(t.TempDir())
+	issue := fixIssue("python.eval", "missing.py", "eval(data)\n", "eval(data)", "")
+
+	// Act
+	results := plan.fingerprintIssues([]codacy.Result{issue})
+
+	// Assert
+	assert.Equal(t, fingerprint("python.eval\x00missing.py\x00", 0), results[0].(Issue).Fingerprint)
+}
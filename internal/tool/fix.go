@@ -0,0 +1,159 @@
+package tool
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+)
+
+// FixPlan are the fixes of an analysis, by file, that can be applied together,
+// and the ones that were skipped.
+type FixPlan struct {
+	sourceDir string
+	Files     []FileFixes
+	Skipped   []SkippedFix
+}
+
+// FileFixes are the fixes of a file, sorted by their position in the file and without overlaps.
+type FileFixes struct {
+	File    string
+	Fixes   []Issue
+	content []byte
+}
+
+// SkippedFix is a fix that can't be applied, and the reason why.
+type SkippedFix struct {
+	Issue  Issue
+	Reason string
+}
+
+// PlanFixes collects the fixes of the issues of an analysis and resolves the conflicts between them.
+// Overlapping fixes are resolved the same way on every run: the fix that starts first wins and,
+// when two fixes start at the same place, the one with the shortest range, then the lowest pattern id.
+// Fixes that don't match the current content of their file are skipped as well.
+func PlanFixes(sourceDir string, results []codacy.Result) (FixPlan, error) {
+	plan := FixPlan{sourceDir: sourceDir}
+
+	issues := lo.FilterMap(results, func(result codacy.Result, _ int) (Issue, bool) {
+		issue, ok := result.(Issue)
+		return issue, ok && issue.Fix != ""
+	})
+	issuesByFile := lo.GroupBy(issues, func(issue Issue) string {
+		return issue.File
+	})
+	files := lo.Keys(issuesByFile)
+	sort.Strings(files)
+
+	for _, file := range files {
+		content, err := os.ReadFile(plan.absolutePath(file))
+		if err != nil {
+			return plan, fmt.Errorf("failed to read file to fix: %s\n%w", file, err)
+		}
+
+		fileIssues := issuesByFile[file]
+		sort.SliceStable(fileIssues, func(i, j int) bool {
+			a, b := fileIssues[i], fileIssues[j]
+			if a.Range.Start.Offset != b.Range.Start.Offset {
+				return a.Range.Start.Offset < b.Range.Start.Offset
+			}
+			if a.Range.End.Offset != b.Range.End.Offset {
+				return a.Range.End.Offset < b.Range.End.Offset
+			}
+			if a.PatternID != b.PatternID {
+				return a.PatternID < b.PatternID
+			}
+			return a.Fix < b.Fix
+		})
+
+		fileFixes := FileFixes{File: file, content: content}
+		for _, issue := range fileIssues {
+			if reason, ok := fileFixes.conflict(issue); ok {
+				plan.Skipped = append(plan.Skipped, SkippedFix{Issue: issue, Reason: reason})
+				continue
+			}
+			fileFixes.Fixes = append(fileFixes.Fixes, issue)
+		}
+		if len(fileFixes.Fixes) > 0 {
+			plan.Files = append(plan.Files, fileFixes)
+		}
+	}
+	return plan, nil
+}
+
+// conflict returns why a fix can't be applied after the fixes already accepted for the file.
+// Fixes are checked by the order of their position in the file.
+func (f FileFixes) conflict(issue Issue) (string, bool) {
+	if !rangeMatchesContent(f.content, issue.Range) {
+		return "the range of the fix doesn't match the file", true
+	}
+	if len(f.Fixes) == 0 {
+		return "", false
+	}
+
+	previous := f.Fixes[len(f.Fixes)-1]
+	if previous.Range == issue.Range && previous.Fix == issue.Fix {
+		return fmt.Sprintf("same fix as %s", previous.PatternID), true
+	}
+	if issue.Range.Start.Offset < previous.Range.End.Offset || issue.Range.Start.Offset == previous.Range.Start.Offset {
+		return fmt.Sprintf("overlaps the fix of %s at line %d", previous.PatternID, previous.Range.Start.Line), true
+	}
+	return "", false
+}
+
+// FixCount is the number of fixes that can be applied.
+func (p FixPlan) FixCount() int {
+	return lo.SumBy(p.Files, func(f FileFixes) int {
+		return len(f.Fixes)
+	})
+}
+
+// Apply rewrites the files with their fixes applied.
+func (p FixPlan) Apply() error {
+	for _, fileFixes := range p.Files {
+		filePath := p.absolutePath(fileFixes.File)
+		fileInfo, err := os.Stat(filePath)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filePath, fileFixes.fixedContent(), fileInfo.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write fixed file: %s\n%w", fileFixes.File, err)
+		}
+	}
+	return nil
+}
+
+// WriteDiff writes the fixes as a unified diff that can be applied with git apply or patch -p1.
+func (p FixPlan) WriteDiff(w io.Writer) error {
+	for _, fileFixes := range p.Files {
+		if err := writeUnifiedDiff(w, fileFixes.File, fileFixes.content, fileFixes.Fixes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p FixPlan) absolutePath(file string) string {
+	return sourcePath(p.sourceDir, file)
+}
+
+// fixedContent returns the content of the file with its fixes applied.
+func (f FileFixes) fixedContent() []byte {
+	return applyFixes(f.content, 0, f.Fixes)
+}
+
+// applyFixes applies sorted, non-overlapping fixes to a part of a file that starts at an offset of the file.
+func applyFixes(content []byte, offset int, fixes []Issue) []byte {
+	var fixed []byte
+	position := 0
+	for _, fix := range fixes {
+		start, end := fix.Range.Start.Offset-offset, fix.Range.End.Offset-offset
+		fixed = append(fixed, content[position:start]...)
+		fixed = append(fixed, fix.Fix...)
+		position = end
+	}
+	return append(fixed, content[position:]...)
+}
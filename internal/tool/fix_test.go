@@ -0,0 +1,184 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func fixIssue(patternID, file, content, matched, fix string) Issue {
+	matchedRange := rangeOf(content, matched)
+	return Issue{
+		Issue: codacy.Issue{PatternID: patternID, File: file, Line: matchedRange.Start.Line},
+		Range: matchedRange,
+		Fix:   fix,
+	}
+}
+
+func TestPlanFixes(t *testing.T) {
+	// Arrange
+	content := "import sys\nif failed: exit(1)\nprint(x)\n"
+	sourceDir := writeSourceFiles(t, map[string]string{"main.py": content})
+	useSysExit := fixIssue("use-sys-exit", "main.py", content, "exit(1)", "sys.exit(1)")
+	exitCode := fixIssue("exit-code", "main.py", content, "exit(1)", "exit(2)")
+	duplicate := fixIssue("use-sys-exit-duplicate", "main.py", content, "exit(1)", "sys.exit(1)")
+	overlapping := fixIssue("no-failed-exit", "main.py", content, "failed: exit(1)", "failed: pass")
+	usePrint := fixIssue("use-logging", "main.py", content, "print(x)", "logging.info(x)")
+	withoutFix := fixIssue("no-print", "main.py", content, "print(x)", "")
+	fileError := codacy.FileError{File: "main.py", Message: "Syntax error: message"}
+
+	// Act
+	plan, err := PlanFixes(sourceDir, []codacy.Result{usePrint, useSysExit, exitCode, duplicate, overlapping, withoutFix, fileError})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, plan.FixCount())
+	assert.Len(t, plan.Files, 1)
+	assert.Equal(t, []Issue{overlapping, usePrint}, plan.Files[0].Fixes)
+	assert.Equal(t, []SkippedFix{
+		{Issue: exitCode, Reason: "overlaps the fix of no-failed-exit at line 2"},
+		{Issue: useSysExit, Reason: "overlaps the fix of no-failed-exit at line 2"},
+		{Issue: duplicate, Reason: "overlaps the fix of no-failed-exit at line 2"},
+	}, plan.Skipped)
+}
+
+func TestPlanFixesResolvesConflictsTheSameWayWhateverTheOrder(t *testing.T) {
+	// Arrange
+	content := "if failed: exit(1)\n"
+	sourceDir := writeSourceFiles(t, map[string]string{"main.py": content})
+	useSysExit := fixIssue("use-sys-exit", "main.py", content, "exit(1)", "sys.exit(1)")
+	duplicate := fixIssue("use-sys-exit-duplicate", "main.py", content, "exit(1)", "sys.exit(1)")
+	exitCode := fixIssue("exit-code", "main.py", content, "exit(1)", "exit(2)")
+
+	for _, results := range [][]codacy.Result{
+		{useSysExit, duplicate, exitCode},
+		{exitCode, duplicate, useSysExit},
+	} {
+		// Act
+		plan, err := PlanFixes(sourceDir, results)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []Issue{exitCode}, plan.Files[0].Fixes)
+		assert.Equal(t, []SkippedFix{
+			{Issue: useSysExit, Reason: "overlaps the fix of exit-code at line 1"},
+			{Issue: duplicate, Reason: "overlaps the fix of exit-code at line 1"},
+		}, plan.Skipped)
+	}
+}
+
+func TestPlanFixesSkipsDuplicateFixes(t *testing.T) {
+	// Arrange
+	content := "if failed: exit(1)\n"
+	sourceDir := writeSourceFiles(t, map[string]string{"main.py": content})
+	useSysExit := fixIssue("use-sys-exit", "main.py", content, "exit(1)", "sys.exit(1)")
+	duplicate := fixIssue("use-sys-exit-duplicate", "main.py", content, "exit(1)", "sys.exit(1)")
+
+	// Act
+	plan, err := PlanFixes(sourceDir, []codacy.Result{duplicate, useSysExit})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []Issue{useSysExit}, plan.Files[0].Fixes)
+	assert.Equal(t, []SkippedFix{{Issue: duplicate, Reason: "same fix as use-sys-exit"}}, plan.Skipped)
+}
+
+func TestPlanFixesSkipsFixesNotMatchingTheFile(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{"main.py": "exit(1)\n"})
+	changedFile := fixIssue("use-sys-exit", "main.py", "import sys\nexit(1)\n", "exit(1)", "sys.exit(1)")
+
+	// Act
+	plan, err := PlanFixes(sourceDir, []codacy.Result{changedFile})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, plan.Files)
+	assert.Equal(t, []SkippedFix{{Issue: changedFile, Reason: "the range of the fix doesn't match the file"}}, plan.Skipped)
+}
+
+func TestPlanFixesWithMissingFile(t *testing.T) {
+	// Arrange
+	missingFile := fixIssue("use-sys-exit", "missing.py", "exit(1)\n", "exit(1)", "sys.exit(1)")
+
+	// Act
+	_, err := PlanFixes(t.TempDir(), []codacy.Result{missingFile})
+
+	// Assert
+	assert.ErrorContains(t, err, "failed to read file to fix: missing.py")
+}
+
+func TestFixPlanApply(t *testing.T) {
+	// Arrange
+	mainContent := "import sys\nif failed: exit(1)\nprint(x)\n"
+	scriptContent := "#!/bin/sh\nexit 1"
+	sourceDir := writeSourceFiles(t, map[string]string{"main.py": mainContent, "bin/run": scriptContent})
+	assert.NoError(t, os.Chmod(filepath.Join(sourceDir, "bin/run"), 0o755))
+	plan, err := PlanFixes(sourceDir, []codacy.Result{
+		fixIssue("use-sys-exit", "main.py", mainContent, "exit(1)", "sys.exit(1)"),
+		fixIssue("use-logging", "main.py", mainContent, "print(x)", "logging.info(x)"),
+		fixIssue("exit-code", "bin/run", scriptContent, "exit 1", "exit 2"),
+	})
+	assert.NoError(t, err)
+
+	// Act
+	err = plan.Apply()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bin/run", "main.py"}, lo.Map(plan.Files, func(f FileFixes, _ int) string { return f.File }))
+	fixedMain, _ := os.ReadFile(filepath.Join(sourceDir, "main.py"))
+	assert.Equal(t, "import sys\nif failed: sys.exit(1)\nlogging.info(x)\n", string(fixedMain))
+	fixedScript, _ := os.ReadFile(filepath.Join(sourceDir, "bin/run"))
+	assert.Equal(t, "#!/bin/sh\nexit 2", string(fixedScript))
+	scriptInfo, _ := os.Stat(filepath.Join(sourceDir, "bin/run"))
+	assert.Equal(t, os.FileMode(0o755), scriptInfo.Mode().Perm())
+}
+
+func TestApplyFixes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		fixes    func(content string) []Issue
+		expected string
+	}{
+		{
+			name:    "replacements",
+			content: "a = eval(x); b = eval(y)\n",
+			fixes: func(content string) []Issue {
+				return []Issue{
+					fixIssue("first", "f", content, "eval(x)", "literal_eval(x)"),
+					fixIssue("second", "f", content, "eval(y)", "literal_eval(y)"),
+				}
+			},
+			expected: "a = literal_eval(x); b = literal_eval(y)\n",
+		},
+		{
+			name:    "deletion of a line",
+			content: "a = 1\ndebugger\nb = 2\n",
+			fixes: func(content string) []Issue {
+				return []Issue{fixIssue("no-debugger", "f", content, "debugger\n", "")}
+			},
+			expected: "a = 1\nb = 2\n",
+		},
+		{
+			name:    "adjacent fixes",
+			content: "ab",
+			fixes: func(content string) []Issue {
+				return []Issue{fixIssue("a", "f", content, "a", "A"), fixIssue("b", "f", content, "b", "B")}
+			},
+			expected: "AB",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fixed := applyFixes([]byte(testCase.content), 0, testCase.fixes(testCase.content))
+
+			assert.Equal(t, testCase.expected, string(fixed))
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/samber/lo"
+)
+
+const (
+	semgrepIgnoreFileName = ".semgrepignore"
+	gitIgnoreFileName     = ".gitignore"
+	// ignoreIncludePrefix includes the patterns of another file in a .semgrepignore, like `:include .gitignore`.
+	ignoreIncludePrefix = ":include "
+)
+
+// vcsDirectories are the directories of version control systems, which are never analysed,
+// whatever the ignore files say.
+var vcsDirectories = []string{".git", ".hg", ".svn", ".bzr", "_darcs", "CVS"}
+
+// defaultIgnorePatterns are ignored when the source directory has no .semgrepignore file, like semgrep does.
+// Unlike semgrep, test files are analysed.
+var defaultIgnorePatterns = []string{
+	".git/",
+	"node_modules/",
+	"bower_components/",
+	"build/",
+	"dist/",
+	"vendor/",
+	".env/",
+	".venv/",
+	"venv/",
+	".tox/",
+	"__pycache__/",
+	".npm/",
+	".yarn/",
+	"*.min.js",
+	".semgrep/",
+	".semgrep_logs/",
+}
+
+// sourceWalker walks a source directory, skipping the files ignored by the default ignore list and by the
+// .gitignore and .semgrepignore files found along the way. Ignored directories are never read.
+type sourceWalker struct {
+	sourceDir string
+	// patterns of a directory are appended when the walk enters it, scoped to it, so they apply to its descendants
+	patterns []gitignore.Pattern
+	addFile  func(string)
+}
+
+func newSourceWalker(sourceDir string, addFile func(string)) *sourceWalker {
+	return &sourceWalker{
+		sourceDir: sourceDir,
+		addFile:   addFile,
+	}
+}
+
+// walk adds every file of the source directory that isn't ignored, with its path relative to the source directory.
+func (w *sourceWalker) walk() error {
+	if _, err := os.Stat(filepath.Join(w.sourceDir, semgrepIgnoreFileName)); errors.Is(err, fs.ErrNotExist) {
+		for _, pattern := range defaultIgnorePatterns {
+			w.patterns = append(w.patterns, gitignore.ParsePattern(pattern, nil))
+		}
+	}
+	return filepath.WalkDir(w.sourceDir, w.processFile)
+}
+
+func (w *sourceWalker) processFile(filePath string, info fs.DirEntry, err error) error {
+	if err != nil {
+		return err
+	}
+	pathInfo, pathErr := info.Info()
+	if pathErr != nil {
+		return pathErr
+	}
+
+	relativePath, err := filepath.Rel(w.sourceDir, filePath)
+	if err != nil {
+		return err
+	}
+	if pathInfo.IsDir() && relativePath != "." && lo.Contains(vcsDirectories, pathInfo.Name()) {
+		return fs.SkipDir
+	}
+	var components []string
+	if relativePath != "." {
+		components = strings.Split(filepath.ToSlash(relativePath), "/")
+		if gitignore.NewMatcher(w.patterns).Match(components, pathInfo.IsDir()) {
+			if pathInfo.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+	}
+
+	if pathInfo.IsDir() {
+		return w.readIgnoreFiles(components)
+	}
+	// if it is not a hidden file
+	if !strings.HasPrefix(pathInfo.Name(), ".") {
+		w.addFile(relativePath)
+	}
+	return nil
+}
+
+// readIgnoreFiles adds the patterns of the ignore files of a directory, given by its path components.
+func (w *sourceWalker) readIgnoreFiles(dir []string) error {
+	for _, ignoreFileName := range []string{gitIgnoreFileName, semgrepIgnoreFileName} {
+		lines, err := readIgnoreFile(filepath.Join(w.sourceDir, path.Join(dir...), ignoreFileName))
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			// Includes are only supported in .semgrepignore files and aren't recursive
+			if includedFileName, ok := strings.CutPrefix(line, ignoreIncludePrefix); ok && ignoreFileName == semgrepIgnoreFileName {
+				includedLines, err := readIgnoreFile(filepath.Join(w.sourceDir, path.Join(dir...), strings.TrimSpace(includedFileName)))
+				if err != nil {
+					return err
+				}
+				for _, includedLine := range includedLines {
+					w.patterns = append(w.patterns, gitignore.ParsePattern(includedLine, dir))
+				}
+				continue
+			}
+			w.patterns = append(w.patterns, gitignore.ParsePattern(line, dir))
+		}
+	}
+	return nil
+}
+
+// readIgnoreFile returns the pattern lines of an ignore file, without comments and blank lines.
+// A missing file has no patterns.
+func readIgnoreFile(fileName string) ([]string, error) {
+	content, err := os.ReadFile(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
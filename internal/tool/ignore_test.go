@@ -0,0 +1,134 @@
+package tool
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func walkSourceFiles(t *testing.T, sourceDir string) []string {
+	var files []string
+	err := newSourceWalker(sourceDir, func(file string) { files = append(files, file) }).walk()
+	assert.NoError(t, err)
+	sort.Strings(files)
+	return files
+}
+
+func TestSourceWalkerSkipsDefaultIgnoredPaths(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{
+		"main.go":                        "package main\n",
+		"web/app.js":                     "app()\n",
+		"web/app.min.js":                 "app()\n",
+		"web/node_modules/lib/index.js":  "lib()\n",
+		"vendor/github.com/lib/lib.go":   "package lib\n",
+		"build/output.js":                "output()\n",
+		".git/objects/ab/cdef":           "blob\n",
+		"scripts/__pycache__/run.pyc":    "pyc\n",
+		"scripts/run.py":                 "run()\n",
+		"scripts/.venv/lib/site.py":      "site()\n",
+		"src/build/generated_sources.go": "package build\n",
+	})
+
+	// Act
+	files := walkSourceFiles(t, sourceDir)
+
+	// Assert
+	assert.Equal(t, []string{"main.go", "scripts/run.py", "web/app.js"}, files)
+}
+
+func TestSourceWalkerHonorsIgnoreFiles(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{
+		".gitignore":              "*.log\ngenerated/\n!keep.log\n",
+		"app.log":                 "log\n",
+		"keep.log":                "log\n",
+		"main.py":                 "main()\n",
+		"generated/models.py":     "models()\n",
+		"service/.gitignore":      "/local.py\n",
+		"service/local.py":        "local()\n",
+		"service/api.py":          "api()\n",
+		"service/nested/local.py": "local()\n",
+		"docs/.semgrepignore":     "# examples aren't analysed\n\nexamples/\n",
+		"docs/examples/demo.py":   "demo()\n",
+		"docs/conf.py":            "conf()\n",
+	})
+
+	// Act
+	files := walkSourceFiles(t, sourceDir)
+
+	// Assert
+	assert.Equal(t, []string{"docs/conf.py", "keep.log", "main.py", "service/api.py", "service/nested/local.py"}, files)
+}
+
+func TestSourceWalkerReplacesDefaultsWithSemgrepIgnore(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{
+		".semgrepignore":         ":include .gitignore\ntests/\n",
+		".gitignore":             "*.tmp\n",
+		"cache.tmp":              "tmp\n",
+		"tests/test_main.py":     "test()\n",
+		"vendor/lib/lib.go":      "package lib\n",
+		"node_modules/a/a.js":    "a()\n",
+		"main.py":                "main()\n",
+		"service/.semgrepignore": ":include .gitignore\n",
+		"service/.gitignore":     "secret.py\n",
+		"service/secret.py":      "secret()\n",
+	})
+
+	// Act
+	files := walkSourceFiles(t, sourceDir)
+
+	// Assert
+	assert.Equal(t, []string{"main.py", "node_modules/a/a.js", "vendor/lib/lib.go"}, files)
+}
+
+func TestSourceWalkerSkipsVersionControlDirectoriesWithSemgrepIgnore(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{
+		".semgrepignore":         "tests/\n",
+		".git/config":            "[core]\n",
+		".git/objects/ab/cdef":   "blob\n",
+		".git/hooks/pre-commit":  "#!/bin/sh\n",
+		"service/.hg/store/data": "data\n",
+		"service/.svn/entries":   "entries\n",
+		"service/api.py":         "api()\n",
+		"main.py":                "main()\n",
+	})
+
+	// Act
+	files := walkSourceFiles(t, sourceDir)
+
+	// Assert
+	assert.Equal(t, []string{"main.py", "service/api.py"}, files)
+}
+
+func TestSourceWalkerPrunesIgnoredDirectories(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{
+		"main.py": "main()\n",
+		// A .gitignore that can't be read fails the walk, unless its directory is pruned before being read
+		"node_modules/a/.gitignore/invalid": "",
+	})
+
+	// Act
+	files := walkSourceFiles(t, sourceDir)
+
+	// Assert
+	assert.Equal(t, []string{"main.py"}, files)
+}
+
+func TestSourceWalkerWithUnreadableIgnoreFile(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{
+		"main.py":                    "main()\n",
+		"service/.gitignore/invalid": "",
+	})
+
+	// Act
+	err := newSourceWalker(sourceDir, func(string) {}).walk()
+
+	// Assert
+	assert.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package tool
+
+import (
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+)
+
+// Issue is an issue found by semgrep, with the precise range of the code it matched.
+// Codacy only gets the fields of the embedded codacy.Issue, the other output formats of the tool also get the rest.
+type Issue struct {
+	codacy.Issue
+	Range Range
+	// Fix is the code semgrep replaces the range with, when the rule has a fix.
+	// The Suggestion of the issue is the fix applied to the whole lines of the range.
+	Fix string
+	// Trace is how tainted data reaches the code of the issue, for the issues of taint rules.
+	Trace *DataflowTrace
+	// Fingerprint identifies the issue across analyses, even when the code around it changes.
+	Fingerprint string
+}
+
+// Range is the part of a file matched by a rule, from its start to its end, excluded.
+type Range struct {
+	Start Position
+	End   Position
+}
+
+// Position is a location in a file.
+type Position struct {
+	// Line starts at 1
+	Line int
+	// Col starts at 1
+	Col int
+	// Offset is the number of bytes from the start of the file
+	Offset int
+}
+
+func newPosition(location SemgrepLocation) Position {
+	return Position{Line: location.Line, Col: location.Col, Offset: location.Offset}
+}
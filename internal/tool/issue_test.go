@@ -0,0 +1,23 @@
+package tool
+
+import (
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssueToJSONKeepsCodacyFormat(t *testing.T) {
+	// Arrange
+	issue := Issue{
+		Issue: codacy.Issue{PatternID: "rule", File: "a.py", Line: 2, Message: "message"},
+		Range: Range{Start: Position{Line: 2, Col: 5, Offset: 20}, End: Position{Line: 3, Col: 1, Offset: 30}},
+	}
+
+	// Act
+	json, err := issue.ToJSON()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"patternId": "rule", "filename": "a.py", "line": 2, "message": "message"}`, string(json))
+}
@@ -0,0 +1,220 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// EngineLimits are the limits semgrep runs with.
+type EngineLimits struct {
+	// Timeout is the maximum time, in seconds, spent running a rule on a file. 0 disables it.
+	Timeout int
+	// TimeoutThreshold is the number of rules that can time out on a file before it is skipped. 0 disables it.
+	TimeoutThreshold int
+	// MaxMemoryMB is the memory, in megabytes, shared by all semgrep processes of an analysis.
+	MaxMemoryMB int
+	// MaxTargetBytes is the size, in bytes, above which files are not analysed. 0 disables it.
+	MaxTargetBytes int
+	// Jobs is the number of CPUs shared by all semgrep processes of an analysis.
+	Jobs int
+}
+
+// DefaultEngineLimits returns the limits used when none are configured.
+// Jobs and memory are derived from the CPUs and memory available to the container the tool runs in.
+func DefaultEngineLimits() EngineLimits {
+	resources := detectedResources()
+	return EngineLimits{
+		Timeout:          5,
+		TimeoutThreshold: 50,
+		MaxMemoryMB:      max(minEngineMemoryMB, min(maxEngineMemoryMB, resources.usableMemoryMB())),
+		MaxTargetBytes:   0,
+		Jobs:             max(1, min(maxEngineJobs, resources.cpus)),
+	}
+}
+
+const engineLimitsEnvironmentPrefix = "CODACY_SEMGREP_"
+
+const (
+	minEngineMemoryMB = 256
+	maxEngineMemoryMB = 1 << 20
+	maxEngineJobs     = 1024
+)
+
+// engineLimit describes one of the EngineLimits fields: how it is configured and its valid range.
+type engineLimit struct {
+	name  string
+	min   int
+	max   int
+	field func(*EngineLimits) *int
+}
+
+// Sorted from the longest to the shortest name, so TIMEOUT_THRESHOLD_JAVA isn't read as TIMEOUT for THRESHOLD_JAVA.
+var engineLimitsDefinitions = []engineLimit{
+	{name: "TIMEOUT_THRESHOLD", min: 0, max: 10000, field: func(l *EngineLimits) *int { return &l.TimeoutThreshold }},
+	{name: "MAX_TARGET_BYTES", min: 0, max: 1 << 30, field: func(l *EngineLimits) *int { return &l.MaxTargetBytes }},
+	{name: "MAX_MEMORY", min: minEngineMemoryMB, max: maxEngineMemoryMB, field: func(l *EngineLimits) *int { return &l.MaxMemoryMB }},
+	{name: "TIMEOUT", min: 0, max: 3600, field: func(l *EngineLimits) *int { return &l.Timeout }},
+	{name: "JOBS", min: 1, max: maxEngineJobs, field: func(l *EngineLimits) *int { return &l.Jobs }},
+}
+
+func (l EngineLimits) validate() error {
+	for _, definition := range engineLimitsDefinitions {
+		value := *definition.field(&l)
+		if value < definition.min || value > definition.max {
+			return fmt.Errorf("invalid semgrep %s limit %d: must be between %d and %d", strings.ToLower(definition.name), value, definition.min, definition.max)
+		}
+	}
+	return nil
+}
+
+// withDefaults returns the limits with the limits that aren't set, with a zero value, taken from the defaults.
+func (l EngineLimits) withDefaults(defaults EngineLimits) EngineLimits {
+	for _, definition := range engineLimitsDefinitions {
+		if field := definition.field(&l); *field == 0 {
+			*field = *definition.field(&defaults)
+		}
+	}
+	return l
+}
+
+func (l EngineLimits) String() string {
+	return fmt.Sprintf("timeout=%ds timeout_threshold=%d max_memory=%dMB max_target_bytes=%d jobs=%d",
+		l.Timeout, l.TimeoutThreshold, l.MaxMemoryMB, l.MaxTargetBytes, l.Jobs)
+}
+
+// budget is the CPU and memory budget the limits give to all semgrep processes of an analysis.
+func (l EngineLimits) budget() resourceBudget {
+	return resourceBudget{cpus: l.Jobs, memoryMB: l.MaxMemoryMB}
+}
+
+// engineLimitsConfiguration are the limits of an analysis, with the overrides for specific languages.
+type engineLimitsConfiguration struct {
+	global    EngineLimits
+	languages map[string]EngineLimits
+}
+
+// processLimits are the limits of a semgrep process analysing a language with a share of the budget.
+// Jobs and memory overrides of a language can only lower the share the process gets.
+func (c engineLimitsConfiguration) processLimits(language string, share resourceBudget) EngineLimits {
+	limits, ok := c.languages[language]
+	if !ok {
+		limits = c.global
+	}
+	limits.Jobs = min(limits.Jobs, share.cpus)
+	limits.MaxMemoryMB = min(limits.MaxMemoryMB, share.memoryMB)
+	return limits
+}
+
+// resultLimits are the limits of a language that change the results of semgrep, like the files it skips,
+// without the resources it gets.
+func (c engineLimitsConfiguration) resultLimits(language string) EngineLimits {
+	limits := c.processLimits(language, resourceBudget{})
+	limits.Jobs, limits.MaxMemoryMB = 0, 0
+	return limits
+}
+
+func (c engineLimitsConfiguration) validate() error {
+	if err := c.global.validate(); err != nil {
+		return err
+	}
+	for language, limits := range c.languages {
+		if !lo.Contains(semgrepLanguages(), language) {
+			return fmt.Errorf("invalid semgrep limits for unknown language %s", language)
+		}
+		if err := limits.validate(); err != nil {
+			return fmt.Errorf("%s for %s", err.Error(), language)
+		}
+	}
+	return nil
+}
+
+func (c engineLimitsConfiguration) log() {
+	logrus.Infof("semgrep engine limits: %s", c.global)
+	if resources := detectedResources(); c.global.MaxMemoryMB > resources.memoryMB {
+		logrus.Warnf("semgrep max_memory %dMB is above the %dMB of memory available", c.global.MaxMemoryMB, resources.memoryMB)
+	}
+	languages := lo.Keys(c.languages)
+	sort.Strings(languages)
+	for _, language := range languages {
+		logrus.Infof("semgrep engine limits for %s: %s", language, c.languages[language])
+	}
+}
+
+// newEngineLimitsConfiguration applies the environment variables on top of the configured limits and validates the result.
+// Limits are set for all languages with CODACY_SEMGREP_<LIMIT> and for a single one with CODACY_SEMGREP_<LIMIT>_<LANGUAGE>,
+// for example CODACY_SEMGREP_TIMEOUT=10 and CODACY_SEMGREP_TIMEOUT_JAVA=30.
+func newEngineLimitsConfiguration(global EngineLimits, languages map[string]EngineLimits, environment []string) (engineLimitsConfiguration, error) {
+	configuration := engineLimitsConfiguration{
+		global:    global,
+		languages: map[string]EngineLimits{},
+	}
+
+	// Global variables are applied first so the languages without explicit limits inherit them
+	var languageVariables []string
+	for _, variable := range environment {
+		name, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, engineLimitsEnvironmentPrefix) {
+			continue
+		}
+		name = strings.TrimPrefix(name, engineLimitsEnvironmentPrefix)
+
+		definition, ok := lo.Find(engineLimitsDefinitions, func(d engineLimit) bool { return d.name == name })
+		if !ok {
+			languageVariables = append(languageVariables, variable)
+			continue
+		}
+		if err := setEngineLimit(&configuration.global, definition, name, value); err != nil {
+			return configuration, err
+		}
+	}
+
+	// Like the variables of a language, the configured limits of a language only override some of the global ones
+	for language, limits := range languages {
+		configuration.languages[language] = limits.withDefaults(configuration.global)
+	}
+
+	for _, variable := range languageVariables {
+		name, value, _ := strings.Cut(strings.TrimPrefix(variable, engineLimitsEnvironmentPrefix), "=")
+		definition, ok := lo.Find(engineLimitsDefinitions, func(d engineLimit) bool { return strings.HasPrefix(name, d.name+"_") })
+		if !ok {
+			continue
+		}
+		language := strings.ToLower(strings.TrimPrefix(name, definition.name+"_"))
+		limits, ok := configuration.languages[language]
+		if !ok {
+			limits = configuration.global
+		}
+		if err := setEngineLimit(&limits, definition, name, value); err != nil {
+			return configuration, err
+		}
+		configuration.languages[language] = limits
+	}
+
+	return configuration, configuration.validate()
+}
+
+func setEngineLimit(limits *EngineLimits, definition engineLimit, name, value string) error {
+	parsedValue, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return fmt.Errorf("invalid semgrep limit %s%s: %w", engineLimitsEnvironmentPrefix, name, err)
+	}
+	*definition.field(limits) = parsedValue
+	return nil
+}
+
+// semgrepLanguages are the languages the tool runs semgrep with.
+func semgrepLanguages() []string {
+	languages := append(lo.Values(extensionToLanguageMap), lo.Values(fileNameToLanguageMap)...)
+	return lo.Uniq(append(languages, "none"))
+}
+
+// environmentEngineLimitsConfiguration resolves the limits with the environment of the process.
+func environmentEngineLimitsConfiguration(global EngineLimits, languages map[string]EngineLimits) (engineLimitsConfiguration, error) {
+	return newEngineLimitsConfiguration(global, languages, os.Environ())
+}
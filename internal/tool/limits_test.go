@@ -0,0 +1,140 @@
+package tool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEngineLimitsConfigurationWithoutEnvironment(t *testing.T) {
+	// Arrange
+	global := EngineLimits{Timeout: 5, TimeoutThreshold: 50, MaxMemoryMB: 5000, MaxTargetBytes: 0, Jobs: 4}
+
+	// Act
+	configuration, err := newEngineLimitsConfiguration(global, nil, []string{"PATH=/usr/bin"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, global, configuration.global)
+	assert.Empty(t, configuration.languages)
+}
+
+func TestNewEngineLimitsConfigurationWithEnvironment(t *testing.T) {
+	// Arrange
+	global := EngineLimits{Timeout: 5, TimeoutThreshold: 50, MaxMemoryMB: 5000, MaxTargetBytes: 0, Jobs: 4}
+	environment := []string{
+		"CODACY_SEMGREP_TIMEOUT_JAVA=30",
+		"CODACY_SEMGREP_TIMEOUT=10",
+		"CODACY_SEMGREP_TIMEOUT_THRESHOLD=3",
+		"CODACY_SEMGREP_TIMEOUT_THRESHOLD_PYTHON=0",
+		"CODACY_SEMGREP_MAX_MEMORY=2000",
+		"CODACY_SEMGREP_MAX_TARGET_BYTES=1000000",
+		"CODACY_SEMGREP_JOBS=2",
+	}
+
+	// Act
+	configuration, err := newEngineLimitsConfiguration(global, nil, environment)
+
+	// Assert
+	assert.NoError(t, err)
+	expectedGlobal := EngineLimits{Timeout: 10, TimeoutThreshold: 3, MaxMemoryMB: 2000, MaxTargetBytes: 1000000, Jobs: 2}
+	assert.Equal(t, expectedGlobal, configuration.global)
+
+	expectedJava := expectedGlobal
+	expectedJava.Timeout = 30
+	expectedPython := expectedGlobal
+	expectedPython.TimeoutThreshold = 0
+	assert.Equal(t, map[string]EngineLimits{"java": expectedJava, "python": expectedPython}, configuration.languages)
+}
+
+func TestNewEngineLimitsConfigurationEnvironmentOverridesLanguageOption(t *testing.T) {
+	// Arrange
+	global := DefaultEngineLimits()
+	java := global
+	java.Timeout = 60
+
+	// Act
+	configuration, err := newEngineLimitsConfiguration(global, map[string]EngineLimits{"java": java}, []string{"CODACY_SEMGREP_MAX_TARGET_BYTES_JAVA=500"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 60, configuration.languages["java"].Timeout)
+	assert.Equal(t, 500, configuration.languages["java"].MaxTargetBytes)
+}
+
+func TestNewEngineLimitsConfigurationWithPartialLanguageOption(t *testing.T) {
+	// Arrange
+	global := EngineLimits{Timeout: 5, TimeoutThreshold: 50, MaxMemoryMB: 5000, MaxTargetBytes: 0, Jobs: 4}
+
+	// Act
+	configuration, err := newEngineLimitsConfiguration(global, map[string]EngineLimits{"java": {Timeout: 60}}, []string{"CODACY_SEMGREP_JOBS=2"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, EngineLimits{Timeout: 60, TimeoutThreshold: 50, MaxMemoryMB: 5000, MaxTargetBytes: 0, Jobs: 2}, configuration.languages["java"])
+}
+
+func TestNewEngineLimitsConfigurationWithInvalidValues(t *testing.T) {
+	tests := []struct {
+		test_name   string
+		environment []string
+		languages   map[string]EngineLimits
+		expected    string
+	}{
+		{
+			test_name:   "not a number",
+			environment: []string{"CODACY_SEMGREP_TIMEOUT=five"},
+			expected:    "invalid semgrep limit CODACY_SEMGREP_TIMEOUT",
+		},
+		{
+			test_name:   "above the maximum",
+			environment: []string{"CODACY_SEMGREP_TIMEOUT=100000"},
+			expected:    "invalid semgrep timeout limit 100000: must be between 0 and 3600",
+		},
+		{
+			test_name:   "below the minimum",
+			environment: []string{"CODACY_SEMGREP_MAX_MEMORY=10"},
+			expected:    "invalid semgrep max_memory limit 10: must be between 256 and 1048576",
+		},
+		{
+			test_name:   "invalid language limit",
+			environment: []string{"CODACY_SEMGREP_JOBS_GO=0"},
+			expected:    "invalid semgrep jobs limit 0: must be between 1 and 1024 for go",
+		},
+		{
+			test_name:   "unknown language",
+			environment: []string{"CODACY_SEMGREP_TIMEOUT_COBOL=10"},
+			expected:    "invalid semgrep limits for unknown language cobol",
+		},
+		{
+			test_name: "unknown language option",
+			languages: map[string]EngineLimits{"cobol": DefaultEngineLimits()},
+			expected:  "invalid semgrep limits for unknown language cobol",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.test_name, func(t *testing.T) {
+			_, err := newEngineLimitsConfiguration(DefaultEngineLimits(), test.languages, test.environment)
+
+			assert.ErrorContains(t, err, test.expected)
+		})
+	}
+}
+
+func TestProcessLimits(t *testing.T) {
+	// Arrange
+	global := EngineLimits{Timeout: 5, TimeoutThreshold: 50, MaxMemoryMB: 8000, MaxTargetBytes: 0, Jobs: 8}
+	java := global
+	java.Timeout = 30
+	java.Jobs = 1
+	configuration := engineLimitsConfiguration{global: global, languages: map[string]EngineLimits{"java": java}}
+	share := resourceBudget{cpus: 2, memoryMB: 4000}
+
+	// Act
+	goLimits := configuration.processLimits("go", share)
+	javaLimits := configuration.processLimits("java", share)
+
+	// Assert
+	assert.Equal(t, EngineLimits{Timeout: 5, TimeoutThreshold: 50, MaxMemoryMB: 4000, MaxTargetBytes: 0, Jobs: 2}, goLimits)
+	assert.Equal(t, EngineLimits{Timeout: 30, TimeoutThreshold: 50, MaxMemoryMB: 4000, MaxTargetBytes: 0, Jobs: 1}, javaLimits)
+}
@@ -0,0 +1,86 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// SourceConfigurationMode is how the semgrep configuration of the repository is used.
+type SourceConfigurationMode string
+
+const (
+	// SourceConfigurationReplace uses the configuration of the repository instead of the Codacy patterns,
+	// but only when no patterns are configured in Codacy.
+	SourceConfigurationReplace SourceConfigurationMode = "replace"
+	// SourceConfigurationMerge adds the rules of the repository to the rules of the Codacy patterns.
+	// A repository rule with the id of a Codacy rule overrides it, or disables it with `disabled: true`.
+	SourceConfigurationMerge SourceConfigurationMode = "merge"
+)
+
+const sourceConfigurationModeEnvironmentVariable = "CODACY_SEMGREP_CONFIGURATION_MODE"
+
+func (m SourceConfigurationMode) validate() error {
+	if m != SourceConfigurationReplace && m != SourceConfigurationMerge {
+		return fmt.Errorf("invalid semgrep configuration mode %q: must be %s or %s", m, SourceConfigurationReplace, SourceConfigurationMerge)
+	}
+	return nil
+}
+
+// environmentSourceConfigurationMode resolves the configuration mode with the environment of the process,
+// which takes precedence over the configured one.
+func environmentSourceConfigurationMode(mode SourceConfigurationMode) (SourceConfigurationMode, error) {
+	if value, ok := os.LookupEnv(sourceConfigurationModeEnvironmentVariable); ok {
+		mode = SourceConfigurationMode(strings.ToLower(strings.TrimSpace(value)))
+	}
+	return mode, mode.validate()
+}
+
+// mergeRuleSets combines the bundled rules with the rules of the repository.
+// A repository rule with the id of a bundled rule takes its place, unless it has `disabled: true`,
+// in which case both are left out. The other repository rules are added after the bundled ones.
+// Repository rules are expected to have unique ids, see sourceConfiguration.readRuleSet.
+func mergeRuleSets(bundled, source *ruleSet, sourceName string) *ruleSet {
+	sourceRulesByID := lo.KeyBy(source.rules, ruleID)
+
+	merged := &ruleSet{}
+	overridden := map[string]bool{}
+	for _, rule := range bundled.rules {
+		id := ruleID(rule)
+		sourceRule, found := sourceRulesByID[id]
+		if !found {
+			merged.rules = append(merged.rules, rule)
+			continue
+		}
+
+		overridden[id] = true
+		if ruleIsDisabled(sourceRule) {
+			logrus.Infof("semgrep rule %s is disabled by %s", id, sourceName)
+			continue
+		}
+		logrus.Infof("semgrep rule %s is overridden by %s", id, sourceName)
+		merged.rules = append(merged.rules, sourceRule)
+	}
+
+	for _, rule := range source.rules {
+		if overridden[ruleID(rule)] || ruleIsDisabled(rule) {
+			continue
+		}
+		merged.rules = append(merged.rules, rule)
+	}
+	return merged
+}
+
+// ruleIsDisabled reports whether a rule has `disabled: true`.
+func ruleIsDisabled(rule *yaml.Node) bool {
+	value := mappingValue(rule, "disabled")
+	if value == nil {
+		return false
+	}
+	var disabled bool
+	return value.Decode(&disabled) == nil && disabled
+}
@@ -0,0 +1,115 @@
+package tool
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+const mergeRulesDefinition = `rules:
+  - id: codacy.python.kept
+    languages: [python]
+    message: kept
+    pattern: kept()
+  - id: codacy.python.overridden
+    languages: [python]
+    message: bundled
+    pattern: bundled()
+  - id: codacy.python.disabled
+    languages: [python]
+    message: disabled
+    pattern: disabled()
+  - id: codacy.python.not-configured
+    languages: [python]
+    message: not configured
+    pattern: not_configured()
+`
+
+var mergePatterns = []codacy.Pattern{
+	{ID: "codacy.python.kept"},
+	{ID: "codacy.python.overridden"},
+	{ID: "codacy.python.disabled"},
+}
+
+func TestCreateAndWriteMergedConfigurationFile(t *testing.T) {
+	// Arrange
+	sourceConfiguration := `rules:
+  - id: org.python.custom
+    languages: [python]
+    message: custom
+    pattern: custom()
+  - id: codacy.python.overridden
+    languages: [python]
+    message: repository
+    pattern: repository()
+  - id: codacy.python.disabled
+    disabled: true
+`
+
+	sourceRules, err := readRuleSet(strings.NewReader(sourceConfiguration), ".semgrep.yaml")
+	assert.NoError(t, err)
+
+	// Act
+	configurationFile, err := createAndWriteMergedConfigurationFile(strings.NewReader(mergeRulesDefinition), mergePatterns, sourceRules, ".semgrep.yaml")
+
+	// Assert
+	assert.NoError(t, err)
+	defer os.Remove(configurationFile.Name())
+	content, err := os.Open(configurationFile.Name())
+	assert.NoError(t, err)
+	defer content.Close()
+	rules, err := readRuleSet(content, configurationFile.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"codacy.python.kept", "codacy.python.overridden", "org.python.custom"}, lo.Map(rules.rules, func(rule *yaml.Node, _ int) string {
+		return ruleID(rule)
+	}))
+	assert.Equal(t, "repository", mappingValue(rules.rules[1], "message").Value)
+}
+
+func TestNewConfigurationFileKeepsSourceConfigurationInReplaceMode(t *testing.T) {
+	// Arrange
+	sourceDir := t.TempDir()
+	sourceConfigurationPath := sourceDir + "/.semgrep.yaml"
+	assert.NoError(t, os.WriteFile(sourceConfigurationPath, []byte("rules: []\n"), 0644))
+	toolExecution := codacy.ToolExecution{
+		SourceDir:      sourceDir,
+		ToolDefinition: codacy.ToolDefinition{Patterns: &[]codacy.Pattern{}},
+	}
+
+	// Act
+	configurationFile, err := newConfigurationFile(toolExecution, SourceConfigurationReplace, DefaultDocsDir)
+
+	// Assert
+	assert.NoError(t, err)
+	defer configurationFile.Close()
+	assert.Equal(t, sourceConfigurationPath, configurationFile.Name())
+}
+
+func TestEnvironmentSourceConfigurationMode(t *testing.T) {
+	t.Run("configured mode without environment", func(t *testing.T) {
+		mode, err := environmentSourceConfigurationMode(SourceConfigurationMerge)
+
+		assert.NoError(t, err)
+		assert.Equal(t, SourceConfigurationMerge, mode)
+	})
+	t.Run("environment overrides configured mode", func(t *testing.T) {
+		t.Setenv(sourceConfigurationModeEnvironmentVariable, " Merge ")
+
+		mode, err := environmentSourceConfigurationMode(SourceConfigurationReplace)
+
+		assert.NoError(t, err)
+		assert.Equal(t, SourceConfigurationMerge, mode)
+	})
+	t.Run("invalid mode", func(t *testing.T) {
+		t.Setenv(sourceConfigurationModeEnvironmentVariable, "append")
+
+		_, err := environmentSourceConfigurationMode(SourceConfigurationReplace)
+
+		assert.EqualError(t, err, `invalid semgrep configuration mode "append": must be replace or merge`)
+	})
+}
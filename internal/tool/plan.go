@@ -0,0 +1,207 @@
+package tool
+
+import (
+	"errors"
+	"os"
+	"sort"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+)
+
+// analysisPlan holds the state of a single analysis: the semgrep configuration and limits, the descriptions
+// of the patterns that can be reported and the languages each file is analysed with.
+// A new plan is built on every Run, so executions never share state.
+type analysisPlan struct {
+	sourceDir           string
+	semgrepEngine       SemgrepEngine
+	configurationFile   *os.File
+	patternDescriptions *[]codacy.PatternDescription
+	engineLimits        engineLimitsConfiguration
+	// agnosticConfigurationFile has the rules that run on every text file, with agnosticLanguages
+	agnosticConfigurationFile *os.File
+	agnosticLanguages         []string
+	// files keeps the order in which files were added, so jobs are built deterministically
+	files           []string
+	languagesByFile map[string][]string
+	// changes limit the analysis to the files changed in the git repository, and their changed lines
+	// when changedLinesOnly is set
+	changes          *sourceChanges
+	changedLinesOnly bool
+	// cache has the results of the files analysed before, with the hashes of the configuration files,
+	// the hashes of the files of the plan and the targets whose results were found in the cache
+	cache                     *resultCache
+	configurationHash         string
+	agnosticConfigurationHash string
+	fileHashes                map[string]string
+	cachedTargets             map[cacheTarget]bool
+	// fileContents caches the files read to post-process the results
+	fileContents map[string][]byte
+}
+
+// analysisSettings are the settings of the tool resolved for an analysis, with the environment applied.
+type analysisSettings struct {
+	engineLimits            engineLimitsConfiguration
+	sourceConfigurationMode SourceConfigurationMode
+	diffScope               DiffScope
+	changes                 *sourceChanges
+	cache                   *resultCache
+	docsDir                 string
+	semgrepEngine           SemgrepEngine
+}
+
+func newAnalysisPlan(sourceDir string) *analysisPlan {
+	return &analysisPlan{
+		sourceDir:       sourceDir,
+		languagesByFile: map[string][]string{},
+		fileContents:    map[string][]byte{},
+		fileHashes:      map[string]string{},
+		cachedTargets:   map[cacheTarget]bool{},
+	}
+}
+
+// prepareAnalysisPlan builds the plan for the tool execution.
+// The plan has no configuration file when there are no patterns to analyse with.
+func prepareAnalysisPlan(toolExecution codacy.ToolExecution, settings analysisSettings) (*analysisPlan, error) {
+	plan := newAnalysisPlan(toolExecution.SourceDir)
+	plan.engineLimits = settings.engineLimits
+	plan.semgrepEngine = settings.semgrepEngine
+	plan.changes = settings.changes
+	plan.changedLinesOnly = settings.diffScope.ChangedLinesOnly
+
+	configurationFile, err := newConfigurationFile(toolExecution, settings.sourceConfigurationMode, settings.docsDir)
+	if err != nil {
+		return nil, err
+	}
+	if configurationFile == nil {
+		return plan, nil
+	}
+	plan.configurationFile = configurationFile
+
+	agnosticConfigurationFile, agnosticLanguages, err := newAgnosticConfigurationFile(configurationFile.Name())
+	if err != nil {
+		plan.close()
+		return nil, err
+	}
+	plan.agnosticConfigurationFile = agnosticConfigurationFile
+	plan.agnosticLanguages = agnosticLanguages
+
+	err = plan.populateFilesByLanguage(toolExecution.Files, toolExecution.SourceDir)
+	if err != nil {
+		plan.close()
+		return nil, errors.New("Error getting files to analyse: " + err.Error())
+	}
+	if plan.changes != nil {
+		plan.keepChangedFiles(plan.changes)
+	}
+
+	if settings.cache != nil {
+		if err := plan.useCache(settings.cache); err != nil {
+			plan.close()
+			return nil, err
+		}
+	}
+
+	patternDescriptions, err := LoadPatternDescriptions(settings.docsDir)
+	if err != nil {
+		plan.close()
+		return nil, err
+	}
+	plan.patternDescriptions = patternDescriptions
+
+	return plan, nil
+}
+
+// filesByLanguage groups the files of the plan by the languages they are analysed with.
+// A file with more than one language is in the group of each of them.
+func (p *analysisPlan) filesByLanguage() map[string][]string {
+	filesByLanguage := map[string][]string{}
+	for _, file := range p.files {
+		for _, language := range p.languagesByFile[file] {
+			filesByLanguage[language] = append(filesByLanguage[language], file)
+		}
+	}
+	return filesByLanguage
+}
+
+// jobs returns the semgrep jobs for the files of each language, split in batches sized for the budget,
+// followed by the jobs running the agnostic rules on every text file.
+// Jobs are sorted by language so results are merged in a stable order.
+func (p *analysisPlan) jobs(budget resourceBudget) []semgrepJob {
+	filesByLanguage := p.filesByLanguage()
+
+	languages := lo.Keys(filesByLanguage)
+	sort.Strings(languages)
+
+	maxSourceBytes := maxBatchSourceBytes(budget)
+	jobs := lo.FlatMap(languages, func(language string, _ int) []semgrepJob {
+		batches := batchFiles(p.uncachedFiles(language, false, filesByLanguage[language]), p.fileSize, maxSourceBytes)
+		return lo.Map(batches, func(files []string, _ int) semgrepJob {
+			return semgrepJob{language: language, files: files}
+		})
+	})
+	if len(p.agnosticLanguages) == 0 {
+		return jobs
+	}
+
+	textFiles := p.textFiles()
+	for _, language := range p.agnosticLanguages {
+		for _, files := range batchFiles(p.uncachedFiles(language, true, textFiles), p.fileSize, maxSourceBytes) {
+			jobs = append(jobs, semgrepJob{language: language, files: files, agnostic: true})
+		}
+	}
+	return jobs
+}
+
+// configurationFileOf returns the configuration file a job runs with.
+func (p *analysisPlan) configurationFileOf(job semgrepJob) *os.File {
+	if job.agnostic {
+		return p.agnosticConfigurationFile
+	}
+	return p.configurationFile
+}
+
+// textFiles returns the files of the plan the agnostic rules run on.
+func (p *analysisPlan) textFiles() []string {
+	return lo.Filter(p.files, func(file string, _ int) bool {
+		return p.isTextFile(file)
+	})
+}
+
+// useCache looks up and stores the results of the plan in the cache,
+// for the current content of the configuration files.
+func (p *analysisPlan) useCache(cache *resultCache) error {
+	configurationHash, err := hashFile(p.configurationFile.Name())
+	if err != nil {
+		return err
+	}
+	p.configurationHash = configurationHash
+	if p.agnosticConfigurationFile != nil {
+		if p.agnosticConfigurationHash, err = hashFile(p.agnosticConfigurationFile.Name()); err != nil {
+			return err
+		}
+	}
+	p.cache = cache
+	return nil
+}
+
+// fileContent returns the content of a file of the plan, read once for all the results.
+// Files that can't be read have no content.
+func (p *analysisPlan) fileContent(file string) []byte {
+	content, read := p.fileContents[file]
+	if !read {
+		content, _ = os.ReadFile(p.absolutePath(file))
+		p.fileContents[file] = content
+	}
+	return content
+}
+
+// close releases the resources of the plan, like the generated configuration file.
+func (p *analysisPlan) close() {
+	if p.configurationFile != nil {
+		cleanUpConfigurationFile(p.configurationFile, p.sourceDir)
+	}
+	if p.agnosticConfigurationFile != nil {
+		cleanUpConfigurationFile(p.agnosticConfigurationFile, p.sourceDir)
+	}
+}
@@ -0,0 +1,71 @@
+package tool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalysisPlansDoNotShareFiles(t *testing.T) {
+	// Arrange
+	firstPlan := newAnalysisPlan("")
+	secondPlan := newAnalysisPlan("")
+
+	// Act
+	err := firstPlan.populateFilesByLanguage(&[]string{"main.go"}, "")
+	assert.NoError(t, err)
+	err = secondPlan.populateFilesByLanguage(&[]string{"script.py"}, "")
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, map[string][]string{"go": {"main.go"}}, firstPlan.filesByLanguage())
+	assert.Equal(t, map[string][]string{"python": {"script.py"}}, secondPlan.filesByLanguage())
+}
+
+func TestAnalysisPlanAddsFilesOnce(t *testing.T) {
+	// Arrange
+	plan := newAnalysisPlan("")
+
+	// Act
+	plan.addFileToFilesByLanguage("main.go")
+	plan.addFileToFilesByLanguage("main.go")
+
+	// Assert
+	assert.Equal(t, []string{"main.go"}, plan.filesByLanguage()["go"])
+}
+
+func TestAnalysisPlanJobsAreSortedByLanguage(t *testing.T) {
+	// Arrange
+	plan := newAnalysisPlan("")
+	files := []string{"b.py", "main.go", "a.py", "App.java"}
+
+	// Act
+	err := plan.populateFilesByLanguage(&files, "")
+	jobs := plan.jobs(DefaultEngineLimits().budget())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []semgrepJob{
+		{language: "go", files: []string{"main.go"}},
+		{language: "java", files: []string{"App.java"}},
+		{language: "python", files: []string{"b.py", "a.py"}},
+	}, jobs)
+}
+
+func TestAnalysisPlanJobsIncludeFilesInEveryLanguage(t *testing.T) {
+	// Arrange
+	plan := newAnalysisPlan("")
+	files := []string{"include/api.h", "src/api.c", "deploy.yaml"}
+
+	// Act
+	err := plan.populateFilesByLanguage(&files, "")
+	jobs := plan.jobs(DefaultEngineLimits().budget())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []semgrepJob{
+		{language: "c", files: []string{"include/api.h", "src/api.c"}},
+		{language: "cpp", files: []string{"include/api.h"}},
+		{language: "yaml", files: []string{"deploy.yaml"}},
+	}, jobs)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package tool
+
+import "os/exec"
+
+// killProcessGroupOnCancel keeps the default behaviour of killing only the command's own process,
+// as process groups are not available on this platform.
+func killProcessGroupOnCancel(_ *exec.Cmd) {}
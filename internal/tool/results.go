@@ -0,0 +1,33 @@
+package tool
+
+import (
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+)
+
+// issueLocation identifies an issue reported by a rule, whatever the language pass that found it.
+type issueLocation struct {
+	patternID string
+	file      string
+	matched   Range
+}
+
+// deduplicateIssues removes the issues a rule reports more than once for the same location,
+// which happens when a file is analysed with more than one language.
+// The first issue and the order of the results are kept.
+func deduplicateIssues(results []codacy.Result) []codacy.Result {
+	seenIssues := map[issueLocation]bool{}
+
+	return lo.Filter(results, func(result codacy.Result, _ int) bool {
+		issue, ok := result.(Issue)
+		if !ok {
+			return true
+		}
+		location := issueLocation{patternID: issue.PatternID, file: issue.File, matched: issue.Range}
+		if seenIssues[location] {
+			return false
+		}
+		seenIssues[location] = true
+		return true
+	})
+}
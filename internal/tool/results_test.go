@@ -0,0 +1,50 @@
+package tool
+
+import (
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeduplicateIssues(t *testing.T) {
+	// Arrange
+	results := []codacy.Result{
+		Issue{Issue: codacy.Issue{PatternID: "rule", File: "api.h", Line: 1, Message: "from c"}, Range: lineRange(1)},
+		codacy.FileError{File: "api.h", Message: "Syntax error: c"},
+		Issue{Issue: codacy.Issue{PatternID: "rule", File: "api.h", Line: 1, Message: "from cpp"}, Range: lineRange(1)},
+		Issue{Issue: codacy.Issue{PatternID: "rule", File: "api.h", Line: 2, Message: "other line"}, Range: lineRange(2)},
+		Issue{Issue: codacy.Issue{PatternID: "other-rule", File: "api.h", Line: 1, Message: "other rule"}, Range: lineRange(1)},
+		Issue{Issue: codacy.Issue{PatternID: "rule", File: "api.c", Line: 1, Message: "other file"}, Range: lineRange(1)},
+		codacy.FileError{File: "api.h", Message: "Syntax error: cpp"},
+	}
+
+	// Act
+	deduplicated := deduplicateIssues(results)
+
+	// Assert
+	assert.Equal(t, []codacy.Result{
+		Issue{Issue: codacy.Issue{PatternID: "rule", File: "api.h", Line: 1, Message: "from c"}, Range: lineRange(1)},
+		codacy.FileError{File: "api.h", Message: "Syntax error: c"},
+		Issue{Issue: codacy.Issue{PatternID: "rule", File: "api.h", Line: 2, Message: "other line"}, Range: lineRange(2)},
+		Issue{Issue: codacy.Issue{PatternID: "other-rule", File: "api.h", Line: 1, Message: "other rule"}, Range: lineRange(1)},
+		Issue{Issue: codacy.Issue{PatternID: "rule", File: "api.c", Line: 1, Message: "other file"}, Range: lineRange(1)},
+		codacy.FileError{File: "api.h", Message: "Syntax error: cpp"},
+	}, deduplicated)
+}
+
+func lineRange(line int) Range {
+	return Range{Start: Position{Line: line, Col: 1}, End: Position{Line: line, Col: 10}}
+}
+
+func TestDeduplicateIssuesKeepsDifferentRangesOfTheSameLine(t *testing.T) {
+	// Arrange
+	first := Issue{Issue: codacy.Issue{PatternID: "rule", File: "a.py", Line: 1}, Range: Range{Start: Position{Line: 1, Col: 1}, End: Position{Line: 1, Col: 5}}}
+	second := Issue{Issue: codacy.Issue{PatternID: "rule", File: "a.py", Line: 1}, Range: Range{Start: Position{Line: 1, Col: 8}, End: Position{Line: 1, Col: 12}}}
+
+	// Act
+	deduplicated := deduplicateIssues([]codacy.Result{first, second, first})
+
+	// Assert
+	assert.Equal(t, []codacy.Result{first, second}, deduplicated)
+}
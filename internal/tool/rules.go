@@ -0,0 +1,165 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// ruleSet is a list of semgrep rules kept as YAML nodes, so they are written back exactly as they were defined.
+type ruleSet struct {
+	rules []*yaml.Node
+}
+
+// semgrepConfigurationDocument is the structure of a semgrep configuration file, `rules:` followed by the list of rules.
+type semgrepConfigurationDocument struct {
+	Rules []*yaml.Node `yaml:"rules"`
+}
+
+// readRuleSet parses the rules of a semgrep configuration.
+// The source is used to identify the configuration in errors.
+func readRuleSet(reader io.Reader, source string) (*ruleSet, error) {
+	var document yaml.Node
+	if err := yaml.NewDecoder(reader).Decode(&document); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse semgrep rules: %s\n%w", source, err)
+	}
+	// An empty document has no rules
+	if len(document.Content) == 0 {
+		return &ruleSet{}, nil
+	}
+
+	rules := mappingValue(document.Content[0], "rules")
+	if rules == nil || (rules.Kind != yaml.SequenceNode && rules.Tag != "!!null") {
+		return nil, fmt.Errorf("invalid semgrep rules: %s must have a list of rules", source)
+	}
+
+	for i, rule := range rules.Content {
+		if ruleID(rule) == "" {
+			return nil, fmt.Errorf("invalid semgrep rule #%d in %s (line %d): missing id", i+1, source, rule.Line)
+		}
+	}
+	return &ruleSet{rules: rules.Content}, nil
+}
+
+// ruleID returns the id of a rule, or an empty string if the rule has none.
+func ruleID(rule *yaml.Node) string {
+	if value := mappingValue(rule, "id"); value != nil && value.Kind == yaml.ScalarNode {
+		return value.Value
+	}
+	return ""
+}
+
+// mappingValue returns the value of a key of a mapping node, or nil if the node isn't a mapping or doesn't have the key.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// selectPatterns returns a copy of the rules configured by the patterns, with the pattern parameters applied.
+// The order of the rules is kept.
+func (r *ruleSet) selectPatterns(patterns []codacy.Pattern) *ruleSet {
+	patternsByID := lo.KeyBy(patterns, func(pattern codacy.Pattern) string {
+		return pattern.ID
+	})
+
+	selected := &ruleSet{}
+	for _, rule := range r.rules {
+		pattern, found := patternsByID[ruleID(rule)]
+		if !found {
+			continue
+		}
+		rule = copyNode(rule)
+		replaceNodeParameterPlaceholders(rule, &pattern)
+		selected.rules = append(selected.rules, rule)
+	}
+	return selected
+}
+
+// replaceNodeParameterPlaceholders replaces the parameter placeholders in every value of the node.
+func replaceNodeParameterPlaceholders(node *yaml.Node, pattern *codacy.Pattern) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		node.Value = replaceParameterPlaceholders(node.Value, pattern)
+	case yaml.MappingNode:
+		// Keys are left untouched
+		for i := 1; i < len(node.Content); i += 2 {
+			replaceNodeParameterPlaceholders(node.Content[i], pattern)
+		}
+	default:
+		for _, child := range node.Content {
+			replaceNodeParameterPlaceholders(child, pattern)
+		}
+	}
+}
+
+func copyNode(node *yaml.Node) *yaml.Node {
+	nodeCopy := *node
+	nodeCopy.Content = lo.Map(node.Content, func(child *yaml.Node, _ int) *yaml.Node {
+		return copyNode(child)
+	})
+	return &nodeCopy
+}
+
+// writeConfigurationFile writes the rules to a new temporary semgrep configuration file,
+// and validates it can be read back before semgrep runs with it.
+func writeConfigurationFile(rules *ruleSet) (*os.File, error) {
+	configurationFile, err := os.CreateTemp(os.TempDir(), "semgrep-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	encoder := yaml.NewEncoder(configurationFile)
+	encoder.SetIndent(2)
+	document := semgrepConfigurationDocument{Rules: rules.rules}
+	if document.Rules == nil {
+		document.Rules = []*yaml.Node{}
+	}
+	if err := encoder.Encode(document); err != nil {
+		cleanUpConfigurationFile(configurationFile, "")
+		return nil, fmt.Errorf("failed to write semgrep configuration file: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		cleanUpConfigurationFile(configurationFile, "")
+		return nil, fmt.Errorf("failed to write semgrep configuration file: %w", err)
+	}
+
+	if err := validateConfigurationFile(configurationFile.Name(), len(rules.rules)); err != nil {
+		cleanUpConfigurationFile(configurationFile, "")
+		return nil, err
+	}
+	return configurationFile, nil
+}
+
+func validateConfigurationFile(fileName string, expectedRules int) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writtenRules, err := readRuleSet(file, fileName)
+	if err != nil {
+		return fmt.Errorf("invalid semgrep configuration file generated: %w", err)
+	}
+	if len(writtenRules.rules) != expectedRules {
+		return fmt.Errorf("invalid semgrep configuration file generated: %s has %d rules instead of %d", fileName, len(writtenRules.rules), expectedRules)
+	}
+	for _, rule := range writtenRules.rules {
+		if languages := mappingValue(rule, "languages"); languages == nil || len(languages.Content) == 0 {
+			return fmt.Errorf("invalid semgrep configuration file generated: rule %s has no languages", ruleID(rule))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package tool
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestReadRuleSet(t *testing.T) {
+	// Arrange
+	content := `rules:
+    -   id: "quoted-id"
+        languages: [python]
+        message: |
+          Don't write
+          - id: nested-id
+        pattern: eval(...)
+    -   id: plain-id
+        languages: [python]
+        message: plain
+        pattern: exec(...)
+`
+
+	// Act
+	rules, err := readRuleSet(strings.NewReader(content), "rules.yaml")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, rules.rules, 2)
+	assert.Equal(t, "quoted-id", ruleID(rules.rules[0]))
+	assert.Equal(t, "plain-id", ruleID(rules.rules[1]))
+}
+
+func TestReadRuleSetWithEmptyDocument(t *testing.T) {
+	// Act
+	rules, err := readRuleSet(strings.NewReader(""), "rules.yaml")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, rules.rules)
+}
+
+func TestReadRuleSetWithInvalidContent(t *testing.T) {
+	tests := []struct {
+		test_name string
+		content   string
+		expected  string
+	}{
+		{
+			test_name: "invalid yaml",
+			content:   "rules:\n  - id: [unclosed\n",
+			expected:  "failed to parse semgrep rules: rules.yaml",
+		},
+		{
+			test_name: "no list of rules",
+			content:   "rules: some-rule\n",
+			expected:  "rules.yaml must have a list of rules",
+		},
+		{
+			test_name: "rule without id",
+			content:   "rules:\n  - languages: [go]\n",
+			expected:  "invalid semgrep rule #1 in rules.yaml (line 2): missing id",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.test_name, func(t *testing.T) {
+			_, err := readRuleSet(strings.NewReader(test.content), "rules.yaml")
+
+			assert.ErrorContains(t, err, test.expected)
+		})
+	}
+}
+
+func TestSelectPatternsReplacesParameterPlaceholders(t *testing.T) {
+	// Arrange
+	content := `rules:
+  - id: insecure-model
+    languages: [csharp]
+    message: "Usage of Insecure LLM Model: $MODEL"
+    patterns:
+      - metavariable-regex:
+          metavariable: $MODEL
+          regex: <!-- MODEL_ALLOW_LIST -->
+  - id: other-rule
+    languages: [csharp]
+    message: "<!-- MODEL_ALLOW_LIST -->"
+`
+	rules, err := readRuleSet(strings.NewReader(content), "rules.yaml")
+	assert.NoError(t, err)
+	patterns := []codacy.Pattern{
+		{
+			ID: "insecure-model",
+			Parameters: []codacy.PatternParameter{
+				{Name: "modelAllowList", Value: "gpt-4o, gemini-2.5-flash"},
+			},
+		},
+	}
+
+	// Act
+	selected := rules.selectPatterns(patterns)
+
+	// Assert
+	assert.Len(t, selected.rules, 1)
+	regex := mappingValue(mappingValue(selected.rules[0], "patterns").Content[0], "metavariable-regex")
+	assert.Equal(t, `^(?!(gpt-4o|gemini-2\.5-flash)$).*`, mappingValue(regex, "regex").Value)
+	// The original rules are left untouched
+	originalRegex := mappingValue(mappingValue(rules.rules[0], "patterns").Content[0], "metavariable-regex")
+	assert.Equal(t, "<!-- MODEL_ALLOW_LIST -->", mappingValue(originalRegex, "regex").Value)
+}
+
+func TestWriteConfigurationFileQuotesValues(t *testing.T) {
+	// Arrange
+	content := `rules:
+  - id: special-characters
+    languages: [generic]
+    message: value
+    pattern-regex: <!-- SECRET_REGEX -->
+`
+	rules, err := readRuleSet(strings.NewReader(content), "rules.yaml")
+	assert.NoError(t, err)
+	patterns := []codacy.Pattern{
+		{
+			ID:         "special-characters",
+			Parameters: []codacy.PatternParameter{{Name: "secretRegex", Value: `: #"quoted" [x]`}},
+		},
+	}
+
+	// Act
+	configurationFile, err := writeConfigurationFile(rules.selectPatterns(patterns))
+	assert.NoError(t, err)
+	defer os.Remove(configurationFile.Name())
+
+	// Assert
+	var written struct {
+		Rules []map[string]interface{} `yaml:"rules"`
+	}
+	writtenContent, err := os.ReadFile(configurationFile.Name())
+	assert.NoError(t, err)
+	assert.NoError(t, yaml.Unmarshal(writtenContent, &written))
+	assert.Equal(t, `: #"quoted" [x]`, written.Rules[0]["pattern-regex"])
+}
+
+func TestWriteConfigurationFileRejectsRulesWithoutLanguages(t *testing.T) {
+	// Arrange
+	rules, err := readRuleSet(strings.NewReader("rules:\n  - id: no-languages\n    message: value\n"), "rules.yaml")
+	assert.NoError(t, err)
+
+	// Act
+	configurationFile, err := writeConfigurationFile(rules)
+
+	// Assert
+	assert.ErrorContains(t, err, "rule no-languages has no languages")
+	assert.Nil(t, configurationFile)
+}
@@ -0,0 +1,186 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+)
+
+const (
+	// minProcessMemoryMB is the least amount of memory, in megabytes, a semgrep process is started with.
+	minProcessMemoryMB = 1000
+	// maxDeadlineMargin is the most time kept after the analysis deadline, to report the results before the deadline
+	// of the whole run, when the results of an interrupted run are discarded.
+	maxDeadlineMargin = 30 * time.Second
+	// deadlineMarginFraction is the fraction of the time left kept as margin, for short deadlines.
+	deadlineMarginFraction = 10
+)
+
+// resourceBudget is an amount of CPUs and memory that semgrep processes can use.
+type resourceBudget struct {
+	cpus     int
+	memoryMB int
+}
+
+// maxParallelism is how many semgrep processes can run at the same time without going below
+// one CPU or minProcessMemoryMB each.
+func (b resourceBudget) maxParallelism() int {
+	return max(1, min(b.cpus, b.memoryMB/minProcessMemoryMB))
+}
+
+// semgrepJob is a single semgrep invocation over a batch of files of the same language.
+// Agnostic jobs run the agnostic rules of the analysis instead of all of them.
+type semgrepJob struct {
+	language string
+	files    []string
+	agnostic bool
+}
+
+type jobExecutor func(ctx context.Context, job semgrepJob, budget resourceBudget) ([]codacy.Result, error)
+
+type jobOutcome struct {
+	index   int
+	budget  resourceBudget
+	results []codacy.Result
+	err     error
+}
+
+// scheduleJobs runs the jobs concurrently, splitting the budget between the jobs that are running.
+// Jobs are started from the biggest to the smallest, so the longest ones don't end up running alone,
+// and each new job gets an equal share of the resources that are still free.
+// Results are merged in the order of the jobs, regardless of the order in which they finish.
+//
+// When the context ends no new jobs are started, and the results of the jobs that completed
+// are returned together with an error wrapping the context error.
+// When a job fails the jobs that are running are cancelled, since their results are discarded.
+func scheduleJobs(ctx context.Context, jobs []semgrepJob, budget resourceBudget, execute jobExecutor) ([]codacy.Result, error) {
+	jobsCtx, cancelJobs := context.WithCancel(ctx)
+	defer cancelJobs()
+
+	startOrder := make([]int, len(jobs))
+	for i := range jobs {
+		startOrder[i] = i
+	}
+	sort.SliceStable(startOrder, func(i, j int) bool {
+		return len(jobs[startOrder[i]].files) > len(jobs[startOrder[j]].files)
+	})
+
+	maxParallelism := budget.maxParallelism()
+	outcomes := make(chan jobOutcome)
+	resultsByJob := make([][]codacy.Result, len(jobs))
+	completed := make([]bool, len(jobs))
+	var firstErr error
+
+	free := budget
+	running := 0
+	next := 0
+	failed := false
+	for running > 0 || (next < len(startOrder) && !failed && ctx.Err() == nil) {
+		for next < len(startOrder) && !failed && ctx.Err() == nil && running < maxParallelism &&
+			(running == 0 || (free.cpus > 0 && free.memoryMB >= minProcessMemoryMB)) {
+			slots := min(maxParallelism-running, len(startOrder)-next)
+			share := resourceBudget{
+				cpus:     max(1, free.cpus/slots),
+				memoryMB: free.memoryMB / slots,
+			}
+			free.cpus -= share.cpus
+			free.memoryMB -= share.memoryMB
+
+			index := startOrder[next]
+			go func(index int, share resourceBudget) {
+				results, err := execute(jobsCtx, jobs[index], share)
+				outcomes <- jobOutcome{index: index, budget: share, results: results, err: err}
+			}(index, share)
+			running++
+			next++
+		}
+
+		outcome := <-outcomes
+		running--
+		free.cpus += outcome.budget.cpus
+		free.memoryMB += outcome.budget.memoryMB
+		if outcome.err != nil {
+			// Stop starting new jobs and kill the running ones, whose errors are then only of being cancelled
+			if !failed {
+				firstErr = outcome.err
+				cancelJobs()
+			}
+			failed = true
+		} else {
+			resultsByJob[outcome.index] = outcome.results
+			completed[outcome.index] = true
+		}
+	}
+
+	// Jobs failing because they were killed when the context ended are not errors of their own
+	if ctx.Err() != nil {
+		var results []codacy.Result
+		for i := range jobs {
+			results = append(results, resultsByJob[i]...)
+		}
+		return results, newInterruptedError(ctx, jobs, completed)
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	var results []codacy.Result
+	for i := range jobs {
+		results = append(results, resultsByJob[i]...)
+	}
+	return results, nil
+}
+
+// interruptedError is the error of an analysis whose context ended before all its jobs completed.
+type interruptedError struct {
+	completedJobs int
+	totalJobs     int
+	// skippedFiles are the files of the jobs that didn't complete, sorted
+	skippedFiles []string
+	err          error
+}
+
+func newInterruptedError(ctx context.Context, jobs []semgrepJob, completed []bool) *interruptedError {
+	var skippedFiles []string
+	for i, job := range jobs {
+		if !completed[i] {
+			skippedFiles = append(skippedFiles, job.files...)
+		}
+	}
+	skippedFiles = lo.Uniq(skippedFiles)
+	sort.Strings(skippedFiles)
+	return &interruptedError{
+		completedJobs: lo.Count(completed, true),
+		totalJobs:     len(jobs),
+		skippedFiles:  skippedFiles,
+		err:           ctx.Err(),
+	}
+}
+
+func (e *interruptedError) Error() string {
+	reason := "was cancelled"
+	if errors.Is(e.err, context.DeadlineExceeded) {
+		reason = "timed out"
+	}
+	return fmt.Sprintf("semgrep analysis %s with %d of %d runs completed: %s", reason, e.completedJobs, e.totalJobs, e.err.Error())
+}
+
+func (e *interruptedError) Unwrap() error {
+	return e.err
+}
+
+// withAnalysisDeadline returns a context that ends a margin before the deadline of ctx, if it has one,
+// so an analysis that runs out of time can still report what it found.
+func withAnalysisDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	margin := min(maxDeadlineMargin, time.Until(deadline)/deadlineMarginFraction)
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
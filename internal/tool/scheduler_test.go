@@ -0,0 +1,234 @@
+package tool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleJobsMergesResultsInJobOrder(t *testing.T) {
+	// Arrange
+	jobs := []semgrepJob{
+		{language: "go", files: []string{"a.go"}},
+		{language: "java", files: []string{"a.java", "b.java", "c.java"}},
+		{language: "python", files: []string{"a.py", "b.py"}},
+	}
+	budget := resourceBudget{cpus: 4, memoryMB: 4000}
+
+	// Act
+	results, err := scheduleJobs(context.Background(), jobs, budget, func(_ context.Context, job semgrepJob, _ resourceBudget) ([]codacy.Result, error) {
+		// The biggest job is started first but finishes last
+		time.Sleep(time.Duration(len(job.files)) * 10 * time.Millisecond)
+		return []codacy.Result{codacy.Issue{PatternID: job.language}}, nil
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []codacy.Result{
+		codacy.Issue{PatternID: "go"},
+		codacy.Issue{PatternID: "java"},
+		codacy.Issue{PatternID: "python"},
+	}, results)
+}
+
+func TestScheduleJobsSplitsBudgetBetweenRunningJobs(t *testing.T) {
+	// Arrange
+	jobs := []semgrepJob{
+		{language: "go"}, {language: "java"}, {language: "python"}, {language: "ruby"}, {language: "rust"},
+	}
+	budget := resourceBudget{cpus: 4, memoryMB: 8000}
+
+	var mutex sync.Mutex
+	var inUse, peak resourceBudget
+	runningJobs, peakRunningJobs := 0, 0
+
+	// Act
+	_, err := scheduleJobs(context.Background(), jobs, budget, func(_ context.Context, _ semgrepJob, share resourceBudget) ([]codacy.Result, error) {
+		mutex.Lock()
+		inUse.cpus += share.cpus
+		inUse.memoryMB += share.memoryMB
+		peak.cpus = max(peak.cpus, inUse.cpus)
+		peak.memoryMB = max(peak.memoryMB, inUse.memoryMB)
+		runningJobs++
+		peakRunningJobs = max(peakRunningJobs, runningJobs)
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mutex.Lock()
+		inUse.cpus -= share.cpus
+		inUse.memoryMB -= share.memoryMB
+		runningJobs--
+		mutex.Unlock()
+		return nil, nil
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, peak.cpus, budget.cpus)
+	assert.LessOrEqual(t, peak.memoryMB, budget.memoryMB)
+	assert.Equal(t, 4, peakRunningJobs)
+}
+
+func TestScheduleJobsGivesWholeBudgetToSingleJob(t *testing.T) {
+	// Arrange
+	jobs := []semgrepJob{{language: "go"}}
+	budget := resourceBudget{cpus: 8, memoryMB: 5000}
+	var received resourceBudget
+
+	// Act
+	_, err := scheduleJobs(context.Background(), jobs, budget, func(_ context.Context, _ semgrepJob, share resourceBudget) ([]codacy.Result, error) {
+		received = share
+		return nil, nil
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, budget, received)
+}
+
+func TestScheduleJobsRunsJobsWithBudgetBelowMinimum(t *testing.T) {
+	// Arrange
+	jobs := []semgrepJob{{language: "go"}, {language: "java"}}
+	budget := resourceBudget{cpus: 2, memoryMB: 500}
+	executed := 0
+
+	// Act
+	_, err := scheduleJobs(context.Background(), jobs, budget, func(_ context.Context, _ semgrepJob, share resourceBudget) ([]codacy.Result, error) {
+		executed++
+		assert.Equal(t, budget, share)
+		return nil, nil
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, executed)
+}
+
+func TestScheduleJobsReturnsError(t *testing.T) {
+	// Arrange
+	jobs := []semgrepJob{{language: "go"}, {language: "java"}}
+
+	// Act
+	results, err := scheduleJobs(context.Background(), jobs, resourceBudget{cpus: 2, memoryMB: 2000}, func(_ context.Context, job semgrepJob, _ resourceBudget) ([]codacy.Result, error) {
+		if job.language == "java" {
+			return nil, assert.AnError
+		}
+		return []codacy.Result{codacy.Issue{PatternID: job.language}}, nil
+	})
+
+	// Assert
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Nil(t, results)
+}
+
+func TestScheduleJobsCancelsRunningJobsWhenJobFails(t *testing.T) {
+	// Arrange
+	jobs := []semgrepJob{{language: "go"}, {language: "java"}}
+	cancelled := make(chan bool, 1)
+
+	// Act
+	results, err := scheduleJobs(context.Background(), jobs, resourceBudget{cpus: 2, memoryMB: 2000}, func(ctx context.Context, job semgrepJob, _ resourceBudget) ([]codacy.Result, error) {
+		if job.language == "java" {
+			return nil, assert.AnError
+		}
+		select {
+		case <-ctx.Done():
+			cancelled <- true
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Second):
+			cancelled <- false
+			return []codacy.Result{codacy.Issue{PatternID: job.language}}, nil
+		}
+	})
+
+	// Assert
+	assert.ErrorIs(t, err, assert.AnError, "Expected the error of the failed job, not of the cancelled one")
+	assert.Nil(t, results)
+	assert.True(t, <-cancelled, "Expected the running job to be cancelled")
+}
+
+func TestScheduleJobsReturnsCompletedResultsWhenContextEnds(t *testing.T) {
+	// Arrange
+	jobs := []semgrepJob{{language: "go"}, {language: "java"}, {language: "python"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Act
+	results, err := scheduleJobs(ctx, jobs, resourceBudget{cpus: 2, memoryMB: 2000}, func(ctx context.Context, job semgrepJob, _ resourceBudget) ([]codacy.Result, error) {
+		if job.language == "java" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return []codacy.Result{codacy.Issue{PatternID: job.language}}, nil
+	})
+
+	// Assert
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "timed out with 2 of 3 runs completed")
+	assert.Equal(t, []codacy.Result{
+		codacy.Issue{PatternID: "go"},
+		codacy.Issue{PatternID: "python"},
+	}, results)
+}
+
+func TestScheduleJobsReportsSkippedFilesWhenContextEnds(t *testing.T) {
+	// Arrange
+	jobs := []semgrepJob{
+		{language: "go", files: []string{"b.go", "a.go"}},
+		{language: "java", files: []string{"A.java"}},
+		{language: "go", files: []string{"a.go"}, agnostic: true},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Act
+	_, err := scheduleJobs(ctx, jobs, resourceBudget{cpus: 3, memoryMB: 3000}, func(ctx context.Context, job semgrepJob, _ resourceBudget) ([]codacy.Result, error) {
+		if job.language == "go" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, nil
+	})
+
+	// Assert
+	var interrupted *interruptedError
+	assert.ErrorAs(t, err, &interrupted)
+	assert.Equal(t, []string{"a.go", "b.go"}, interrupted.skippedFiles)
+}
+
+func TestWithAnalysisDeadline(t *testing.T) {
+	t.Run("without deadline", func(t *testing.T) {
+		ctx, cancel := withAnalysisDeadline(context.Background())
+		defer cancel()
+
+		_, ok := ctx.Deadline()
+		assert.False(t, ok)
+	})
+	t.Run("short deadline keeps a fraction of the time left", func(t *testing.T) {
+		parent, cancelParent := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelParent()
+		parentDeadline, _ := parent.Deadline()
+
+		ctx, cancel := withAnalysisDeadline(parent)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		assert.InDelta(t, time.Second, parentDeadline.Sub(deadline), float64(10*time.Millisecond))
+	})
+	t.Run("long deadline keeps the maximum margin", func(t *testing.T) {
+		parent, cancelParent := context.WithTimeout(context.Background(), time.Hour)
+		defer cancelParent()
+		parentDeadline, _ := parent.Deadline()
+
+		ctx, cancel := withAnalysisDeadline(parent)
+		defer cancel()
+
+		deadline, _ := ctx.Deadline()
+		assert.Equal(t, maxDeadlineMargin, parentDeadline.Sub(deadline))
+	})
+}
@@ -0,0 +1,122 @@
+package tool
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sourceConfigurationFileNames are the semgrep configuration files looked up in the root of the
+// source directory, by order of precedence.
+var sourceConfigurationFileNames = []string{".semgrep.yaml", ".semgrep.yml", "semgrep.yaml", "semgrep.yml"}
+
+// sourceConfigurationDirName is the directory of rule files used when there is no configuration file.
+const sourceConfigurationDirName = ".semgrep"
+
+// sourceConfiguration is the semgrep configuration of the source directory, a single file or a directory of rule files.
+type sourceConfiguration struct {
+	sourceDir string
+	// name is relative to the source directory
+	name  string
+	isDir bool
+}
+
+// findSourceConfiguration returns the semgrep configuration of the source directory, if it has one.
+func findSourceConfiguration(sourceDir string) (sourceConfiguration, bool) {
+	for _, name := range sourceConfigurationFileNames {
+		if fileInfo, err := os.Stat(path.Join(sourceDir, name)); err == nil && !fileInfo.IsDir() {
+			return sourceConfiguration{sourceDir: sourceDir, name: name}, true
+		}
+	}
+	if fileInfo, err := os.Stat(path.Join(sourceDir, sourceConfigurationDirName)); err == nil && fileInfo.IsDir() {
+		return sourceConfiguration{sourceDir: sourceDir, name: sourceConfigurationDirName, isDir: true}, true
+	}
+	return sourceConfiguration{}, false
+}
+
+// isSourceConfigurationFile reports whether the file is one of the configuration files of the source directory.
+func isSourceConfigurationFile(fileName, sourceDir string) bool {
+	for _, name := range sourceConfigurationFileNames {
+		if fileName == path.Join(sourceDir, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleFiles returns the rule files of the configuration, relative to the source directory.
+// The YAML files of a configuration directory are loaded recursively in lexical order,
+// skipping hidden files and rule tests, like semgrep does.
+func (c sourceConfiguration) ruleFiles() ([]string, error) {
+	if !c.isDir {
+		return []string{c.name}, nil
+	}
+
+	var ruleFiles []string
+	err := filepath.WalkDir(path.Join(c.sourceDir, c.name), func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".test.yaml") || strings.HasSuffix(name, ".test.yml") {
+			return nil
+		}
+		if ext := filepath.Ext(name); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		relativePath, err := filepath.Rel(c.sourceDir, filePath)
+		if err != nil {
+			return err
+		}
+		ruleFiles = append(ruleFiles, filepath.ToSlash(relativePath))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read semgrep rules directory: %s\n%w", c.name, err)
+	}
+	return ruleFiles, nil
+}
+
+// readRuleSet loads the rules of every rule file of the configuration.
+// Rule ids must be unique across all rule files, errors are reported against the file that causes them.
+func (c sourceConfiguration) readRuleSet() (*ruleSet, error) {
+	ruleFiles, err := c.ruleFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	type ruleLocation struct {
+		file string
+		line int
+	}
+	locationsByID := map[string]ruleLocation{}
+	rules := &ruleSet{}
+	for _, ruleFile := range ruleFiles {
+		fileRules, err := readRuleFile(path.Join(c.sourceDir, ruleFile), ruleFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range fileRules.rules {
+			id := ruleID(rule)
+			if previous, found := locationsByID[id]; found {
+				return nil, fmt.Errorf("duplicate semgrep rule id %s in %s (line %d), already defined in %s (line %d)", id, ruleFile, rule.Line, previous.file, previous.line)
+			}
+			locationsByID[id] = ruleLocation{file: ruleFile, line: rule.Line}
+		}
+		rules.rules = append(rules.rules, fileRules.rules...)
+	}
+	return rules, nil
+}
+
+func readRuleFile(fileName, source string) (*ruleSet, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readRuleSet(file, source)
+}
@@ -0,0 +1,183 @@
+package tool
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func writeSourceFiles(t *testing.T, files map[string]string) string {
+	sourceDir := t.TempDir()
+	for name, content := range files {
+		filePath := path.Join(sourceDir, name)
+		assert.NoError(t, os.MkdirAll(path.Dir(filePath), 0700))
+		assert.NoError(t, os.WriteFile(filePath, []byte(content), 0600))
+	}
+	return sourceDir
+}
+
+func sourceRule(id string) string {
+	return "  - id: " + id + "\n    languages: [python]\n    message: " + id + "\n    pattern: " + id + "()\n"
+}
+
+func TestFindSourceConfigurationPrecedence(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    []string
+		expected sourceConfiguration
+	}{
+		{name: ".semgrep.yaml first", files: []string{".semgrep.yaml", ".semgrep.yml", "semgrep.yaml", ".semgrep/rules.yaml"}, expected: sourceConfiguration{name: ".semgrep.yaml"}},
+		{name: ".semgrep.yml", files: []string{".semgrep.yml", "semgrep.yaml", "semgrep.yml"}, expected: sourceConfiguration{name: ".semgrep.yml"}},
+		{name: "semgrep.yaml", files: []string{"semgrep.yaml", "semgrep.yml"}, expected: sourceConfiguration{name: "semgrep.yaml"}},
+		{name: "semgrep.yml", files: []string{"semgrep.yml", ".semgrep/rules.yaml"}, expected: sourceConfiguration{name: "semgrep.yml"}},
+		{name: "directory last", files: []string{".semgrep/rules.yaml"}, expected: sourceConfiguration{name: ".semgrep", isDir: true}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			sourceDir := writeSourceFiles(t, lo.SliceToMap(testCase.files, func(name string) (string, string) {
+				return name, "rules: []\n"
+			}))
+
+			configuration, found := findSourceConfiguration(sourceDir)
+
+			assert.True(t, found)
+			testCase.expected.sourceDir = sourceDir
+			assert.Equal(t, testCase.expected, configuration)
+		})
+	}
+}
+
+func TestFindSourceConfigurationWithoutConfiguration(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{"main.py": "print(1)\n"})
+
+	// Act
+	_, found := findSourceConfiguration(sourceDir)
+
+	// Assert
+	assert.False(t, found)
+}
+
+func TestSourceConfigurationDirectoryRuleFiles(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{
+		".semgrep/python.yaml":            "rules: []\n",
+		".semgrep/go/rules.yml":           "rules: []\n",
+		".semgrep/go/rules.test.yaml":     "rules: []\n",
+		".semgrep/.hidden.yaml":           "rules: []\n",
+		".semgrep/README.md":              "# Rules\n",
+		".semgrep/java/nested/a.yaml":     "rules: []\n",
+		".semgrep/java/nested/b.test.yml": "rules: []\n",
+	})
+	configuration, _ := findSourceConfiguration(sourceDir)
+
+	// Act
+	ruleFiles, err := configuration.ruleFiles()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".semgrep/go/rules.yml", ".semgrep/java/nested/a.yaml", ".semgrep/python.yaml"}, ruleFiles)
+}
+
+func TestSourceConfigurationDirectoryReadRuleSet(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{
+		".semgrep/a.yaml": "rules:\n" + sourceRule("org.a"),
+		".semgrep/b.yaml": "rules:\n" + sourceRule("org.b1") + sourceRule("org.b2"),
+	})
+	configuration, _ := findSourceConfiguration(sourceDir)
+
+	// Act
+	rules, err := configuration.readRuleSet()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"org.a", "org.b1", "org.b2"}, lo.Map(rules.rules, func(rule *yaml.Node, _ int) string {
+		return ruleID(rule)
+	}))
+}
+
+func TestSourceConfigurationReadRuleSetWithDuplicateIDs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		files         map[string]string
+		expectedError string
+	}{
+		{
+			name:          "same file",
+			files:         map[string]string{".semgrep.yaml": "rules:\n" + sourceRule("org.a") + sourceRule("org.a")},
+			expectedError: "duplicate semgrep rule id org.a in .semgrep.yaml (line 6), already defined in .semgrep.yaml (line 2)",
+		},
+		{
+			name: "different files",
+			files: map[string]string{
+				".semgrep/a.yaml": "rules:\n" + sourceRule("org.a"),
+				".semgrep/b.yaml": "rules:\n" + sourceRule("org.b") + sourceRule("org.a"),
+			},
+			expectedError: "duplicate semgrep rule id org.a in .semgrep/b.yaml (line 6), already defined in .semgrep/a.yaml (line 2)",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configuration, _ := findSourceConfiguration(writeSourceFiles(t, testCase.files))
+
+			rules, err := configuration.readRuleSet()
+
+			assert.Nil(t, rules)
+			assert.EqualError(t, err, testCase.expectedError)
+		})
+	}
+}
+
+func TestSourceConfigurationReadRuleSetWithInvalidRuleFile(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{
+		".semgrep/a.yaml":     "rules:\n" + sourceRule("org.a"),
+		".semgrep/b/bad.yaml": "rules:\n  - languages: [python]\n",
+	})
+	configuration, _ := findSourceConfiguration(sourceDir)
+
+	// Act
+	rules, err := configuration.readRuleSet()
+
+	// Assert
+	assert.Nil(t, rules)
+	assert.EqualError(t, err, "invalid semgrep rule #1 in .semgrep/b/bad.yaml (line 2): missing id")
+}
+
+func TestGetSourceConfigurationFileMergesDirectory(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{
+		".semgrep/a.yaml": "rules:\n" + sourceRule("org.a"),
+		".semgrep/b.yml":  "rules:\n" + sourceRule("org.b"),
+	})
+
+	// Act
+	configurationFile, err := getSourceConfigurationFile(sourceDir)
+
+	// Assert
+	assert.NoError(t, err)
+	rules, err := readRuleFile(configurationFile.Name(), configurationFile.Name())
+	assert.NoError(t, err)
+	assert.Len(t, rules.rules, 2)
+	// The merged configuration is generated, so it is deleted on clean up
+	cleanUpConfigurationFile(configurationFile, sourceDir)
+	assert.NoFileExists(t, configurationFile.Name())
+}
+
+func TestCleanUpConfigurationFileKeepsAlternateSourceFile(t *testing.T) {
+	// Arrange
+	sourceDir := writeSourceFiles(t, map[string]string{"semgrep.yml": "rules: []\n"})
+	configurationFile, err := getSourceConfigurationFile(sourceDir)
+	assert.NoError(t, err)
+
+	// Act
+	cleanUpConfigurationFile(configurationFile, sourceDir)
+
+	// Assert
+	assert.FileExists(t, path.Join(sourceDir, "semgrep.yml"))
+}
@@ -0,0 +1,63 @@
+package tool
+
+import (
+	"bytes"
+	"strings"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// applySuggestions sets the suggestion of the issues with a fix: the lines of the issue with the fix applied,
+// as Codacy replaces the lines of an issue with its suggestion.
+// Issues whose fix can't be applied cleanly have no suggestion.
+func (p *analysisPlan) applySuggestions(results []codacy.Result) []codacy.Result {
+	return lo.Map(results, func(result codacy.Result, _ int) codacy.Result {
+		issue, ok := result.(Issue)
+		if !ok || issue.Fix == "" {
+			return result
+		}
+
+		suggestion, ok := lineSuggestion(p.fileContent(issue.File), issue.Range, issue.Fix)
+		if !ok {
+			logrus.Debugf("semgrep fix of %s can't be applied to %s:%d", issue.PatternID, issue.File, issue.Line)
+		}
+		issue.Suggestion = suggestion
+		return issue
+	})
+}
+
+// lineSuggestion applies the fix to the range of the content and returns the lines of the range with the fix.
+// Line endings are returned as \n, whatever the line endings of the content.
+// It fails when the range doesn't match the content, like when the file changed after the analysis.
+func lineSuggestion(content []byte, matched Range, fix string) (string, bool) {
+	if !rangeMatchesContent(content, matched) {
+		return "", false
+	}
+	start, end := matched.Start.Offset, matched.End.Offset
+
+	lineStart := bytes.LastIndexByte(content[:start], '\n') + 1
+	lineEnd := len(content)
+	if i := bytes.IndexByte(content[end:], '\n'); i >= 0 {
+		lineEnd = end + i
+	}
+
+	suggestion := string(content[lineStart:start]) + fix + string(content[end:lineEnd])
+	suggestion = strings.TrimSuffix(suggestion, "\r")
+	return strings.ReplaceAll(suggestion, "\r\n", "\n"), true
+}
+
+// rangeMatchesContent reports whether the offsets of the range are in the content and on the lines of the range.
+func rangeMatchesContent(content []byte, matched Range) bool {
+	start, end := matched.Start.Offset, matched.End.Offset
+	if start < 0 || end < start || end > len(content) {
+		return false
+	}
+	return lineOfOffset(content, start) == matched.Start.Line && lineOfOffset(content, end) == matched.End.Line
+}
+
+// lineOfOffset returns the line, starting at 1, of an offset of the content.
+func lineOfOffset(content []byte, offset int) int {
+	return bytes.Count(content[:offset], []byte{'\n'}) + 1
+}
@@ -0,0 +1,127 @@
+package tool
+
+import (
+	"strings"
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+// rangeOf returns the range of the first occurrence of the text in the content.
+func rangeOf(content, text string) Range {
+	start := strings.Index(content, text)
+	end := start + len(text)
+	position := func(offset int) Position {
+		lineStart := strings.LastIndex(content[:offset], "\n") + 1
+		return Position{Line: strings.Count(content[:offset], "\n") + 1, Col: offset - lineStart + 1, Offset: offset}
+	}
+	return Range{Start: position(start), End: position(end)}
+}
+
+func TestLineSuggestion(t *testing.T) {
+	testCases := []struct {
+		name               string
+		content            string
+		matched            string
+		fix                string
+		expectedSuggestion string
+	}{
+		{
+			name:               "partial line",
+			content:            "import sys\nif failed: exit(1)\n",
+			matched:            "exit(1)",
+			fix:                "sys.exit(1)",
+			expectedSuggestion: "if failed: sys.exit(1)",
+		},
+		{
+			name:               "tabs",
+			content:            "func main() {\n\t\tos.Exit(1) // exit\n}\n",
+			matched:            "os.Exit(1)",
+			fix:                "return",
+			expectedSuggestion: "\t\treturn // exit",
+		},
+		{
+			name:               "multi-line match",
+			content:            "x = call(\n    a,\n    b)  # end\ny = 1\n",
+			matched:            "call(\n    a,\n    b)",
+			fix:                "safe_call(a, b)",
+			expectedSuggestion: "x = safe_call(a, b)  # end",
+		},
+		{
+			name:               "multi-line fix",
+			content:            "a = 1; eval(x); b = 2\n",
+			matched:            "eval(x)",
+			fix:                "check(x)\nrun(x)",
+			expectedSuggestion: "a = 1; check(x)\nrun(x); b = 2",
+		},
+		{
+			name:               "CRLF line endings",
+			content:            "x = call(\r\n  a)\r\ny = 1\r\n",
+			matched:            "call(\r\n  a)",
+			fix:                "safe_call(a)",
+			expectedSuggestion: "x = safe_call(a)",
+		},
+		{
+			name:               "CRLF multi-line result",
+			content:            "first\r\nx = call(\r\n  a) + 1\r\n",
+			matched:            "x = call(",
+			fix:                "y = call(",
+			expectedSuggestion: "y = call(",
+		},
+		{
+			name:               "last line without line ending",
+			content:            "x = 1\nexit(1)",
+			matched:            "exit(1)",
+			fix:                "sys.exit(1)",
+			expectedSuggestion: "sys.exit(1)",
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			suggestion, ok := lineSuggestion([]byte(testCase.content), rangeOf(testCase.content, testCase.matched), testCase.fix)
+
+			assert.True(t, ok)
+			assert.Equal(t, testCase.expectedSuggestion, suggestion)
+		})
+	}
+}
+
+func TestLineSuggestionWithRangeNotMatchingContent(t *testing.T) {
+	content := "import sys\nexit(1)\n"
+	matched := rangeOf(content, "exit(1)")
+
+	testCases := map[string]Range{
+		"offset after the end": {Start: matched.Start, End: Position{Line: 2, Offset: len(content) + 1}},
+		"negative offset":      {Start: Position{Line: 1, Offset: -1}, End: matched.End},
+		"end before start":     {Start: matched.End, End: matched.Start},
+		"start line mismatch":  {Start: Position{Line: 1, Offset: matched.Start.Offset}, End: matched.End},
+		"end line mismatch":    {Start: matched.Start, End: Position{Line: 3, Offset: matched.End.Offset}},
+	}
+	for name, invalidRange := range testCases {
+		t.Run(name, func(t *testing.T) {
+			suggestion, ok := lineSuggestion([]byte(content), invalidRange, "sys.exit(1)")
+
+			assert.False(t, ok)
+			assert.Empty(t, suggestion)
+		})
+	}
+}
+
+func TestApplySuggestions(t *testing.T) {
+	// Arrange
+	content := "import sys\nif failed: exit(1)\n"
+	sourceDir := writeSourceFiles(t, map[string]string{"main.py": content})
+	plan := newAnalysisPlan(sourceDir)
+	withFix := Issue{Issue: codacy.Issue{PatternID: "use-sys-exit", File: "main.py", Line: 2}, Range: rangeOf(content, "exit(1)"), Fix: "sys.exit(1)"}
+	withoutFix := Issue{Issue: codacy.Issue{PatternID: "no-exit", File: "main.py", Line: 2}, Range: rangeOf(content, "exit(1)")}
+	missingFile := Issue{Issue: codacy.Issue{PatternID: "use-sys-exit", File: "missing.py", Line: 2}, Range: rangeOf(content, "exit(1)"), Fix: "sys.exit(1)"}
+	fileError := codacy.FileError{File: "main.py", Message: "Syntax error: message"}
+
+	// Act
+	results := plan.applySuggestions([]codacy.Result{withFix, withoutFix, missingFile, fileError})
+
+	// Assert
+	withFix.Suggestion = "if failed: sys.exit(1)"
+	assert.Equal(t, []codacy.Result{withFix, withoutFix, missingFile, fileError}, results)
+}
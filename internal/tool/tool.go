@@ -9,15 +9,128 @@ import (
 	"path/filepath"
 
 	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultDocsDir is where the docs of the tool are in its container.
+	DefaultDocsDir = "/docs"
+	// DefaultSemgrepBinary is the name of the semgrep-core-proprietary binary in the container of the tool.
+	DefaultSemgrepBinary = "semgrep"
 )
 
 // New creates a new instance of Codacy Semgrep.
-func New() codacySemgrep {
-	return codacySemgrep{}
+func New(options ...Option) codacySemgrep {
+	s := codacySemgrep{
+		engineLimits:            DefaultEngineLimits(),
+		languageEngineLimits:    map[string]EngineLimits{},
+		sourceConfigurationMode: SourceConfigurationReplace,
+		docsDir:                 DefaultDocsDir,
+		semgrepEngine:           SemgrepEngine{Binary: DefaultSemgrepBinary, Pro: true},
+	}
+	for _, option := range options {
+		option(&s)
+	}
+	return s
 }
 
 // Codacy Semgrep tool implementation
 type codacySemgrep struct {
+	engineLimits            EngineLimits
+	languageEngineLimits    map[string]EngineLimits
+	sourceConfigurationMode SourceConfigurationMode
+	baselineFile            string
+	ignoreBaseline          bool
+	diffScope               DiffScope
+	cacheSettings           CacheSettings
+	docsDir                 string
+	semgrepEngine           SemgrepEngine
+	failOnTimeout           bool
+}
+
+// Option configures an instance of Codacy Semgrep.
+type Option func(*codacySemgrep)
+
+// WithEngineLimits sets the limits semgrep runs with.
+// Environment variables, like CODACY_SEMGREP_TIMEOUT, take precedence over them.
+func WithEngineLimits(limits EngineLimits) Option {
+	return func(s *codacySemgrep) {
+		s.engineLimits = limits
+	}
+}
+
+// WithLanguageEngineLimits sets the limits semgrep runs with when analysing a language.
+// The limits that aren't set, with a zero value, are the global ones.
+// Environment variables, like CODACY_SEMGREP_TIMEOUT_JAVA, take precedence over them.
+func WithLanguageEngineLimits(language string, limits EngineLimits) Option {
+	return func(s *codacySemgrep) {
+		s.languageEngineLimits[language] = limits
+	}
+}
+
+// WithSourceConfigurationMode sets how the semgrep configuration of the repository is used.
+// The CODACY_SEMGREP_CONFIGURATION_MODE environment variable takes precedence over it.
+func WithSourceConfigurationMode(mode SourceConfigurationMode) Option {
+	return func(s *codacySemgrep) {
+		s.sourceConfigurationMode = mode
+	}
+}
+
+// WithBaseline only reports the issues that aren't in a baseline file, written by the baseline command.
+// A relative file is in the source directory.
+// The CODACY_SEMGREP_BASELINE environment variable takes precedence over it.
+func WithBaseline(fileName string) Option {
+	return func(s *codacySemgrep) {
+		s.baselineFile = fileName
+	}
+}
+
+// WithoutBaseline reports all the issues, even when a baseline is set in the environment,
+// like when writing a baseline.
+func WithoutBaseline() Option {
+	return func(s *codacySemgrep) {
+		s.ignoreBaseline = true
+	}
+}
+
+// WithDiffScope only analyses the changes of the git repository of the source directory.
+// The CODACY_SEMGREP_DIFF_* environment variables take precedence over it.
+func WithDiffScope(scope DiffScope) Option {
+	return func(s *codacySemgrep) {
+		s.diffScope = scope
+	}
+}
+
+// WithCache caches the results of semgrep for each file, so unchanged files aren't analysed again.
+// The CODACY_SEMGREP_CACHE_DIR and CODACY_SEMGREP_CACHE_MAX_SIZE_MB environment variables take precedence over it.
+func WithCache(settings CacheSettings) Option {
+	return func(s *codacySemgrep) {
+		s.cacheSettings = settings
+	}
+}
+
+// WithDocsDir sets the directory of the docs of the tool, with the rules and the descriptions of the patterns,
+// generated by docgen.
+func WithDocsDir(docsDir string) Option {
+	return func(s *codacySemgrep) {
+		s.docsDir = docsDir
+	}
+}
+
+// WithSemgrepEngine sets the semgrep-core binary to run, found with ProbeSemgrepEngine.
+// By default, the tool runs the semgrep-core-proprietary binary of its container.
+func WithSemgrepEngine(engine SemgrepEngine) Option {
+	return func(s *codacySemgrep) {
+		s.semgrepEngine = engine
+	}
+}
+
+// WithFailOnTimeout fails an analysis that runs out of time, instead of reporting its partial results
+// with a file error for each file it didn't analyse, like when its results are fixed or written to a baseline.
+func WithFailOnTimeout() Option {
+	return func(s *codacySemgrep) {
+		s.failOnTimeout = true
+	}
 }
 
 // https://github.com/uber-go/guide/blob/master/style.md#verify-interface-compliance
@@ -25,44 +138,113 @@ var _ codacy.Tool = (*codacySemgrep)(nil)
 
 // Run runs the Semgrep implementation
 func (s codacySemgrep) Run(ctx context.Context, toolExecution codacy.ToolExecution) ([]codacy.Result, error) {
-	configurationFile, patternDescriptions, err := prepareToRun(toolExecution)
+	engineLimits, err := environmentEngineLimitsConfiguration(s.engineLimits, s.languageEngineLimits)
 	if err != nil {
 		return nil, err
 	}
-	if configurationFile == nil {
-		return []codacy.Result{}, nil
-	}
+	engineLimits.log()
 
-	result, err := run(configurationFile, toolExecution, patternDescriptions)
+	sourceConfigurationMode, err := environmentSourceConfigurationMode(s.sourceConfigurationMode)
 	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
-}
+	diffScope, err := environmentDiffScope(s.diffScope)
+	if err != nil {
+		return nil, err
+	}
+	var changes *sourceChanges
+	if diffScope.enabled() {
+		changes, err = diffSourceChanges(ctx, toolExecution.SourceDir, diffScope)
+		if err != nil {
+			return nil, err
+		}
+		logrus.Infof("semgrep analyses the %d files changed between %s and %s", len(changes.files), diffScope.Base, diffScope.Head)
+	}
 
-func prepareToRun(toolExecution codacy.ToolExecution) (*os.File, *[]codacy.PatternDescription, error) {
-	configurationFile, err := newConfigurationFile(toolExecution)
+	cacheSettings, err := environmentCacheSettings(s.cacheSettings)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	cache, err := newResultCache(cacheSettings, s.semgrepEngine.cacheVersion(toolExecution.ToolDefinition.Version))
+	if err != nil {
+		return nil, err
 	}
 
-	err = populateFilesByLanguage(toolExecution.Files, toolExecution.SourceDir)
+	plan, err := prepareAnalysisPlan(toolExecution, analysisSettings{
+		engineLimits:            engineLimits,
+		sourceConfigurationMode: sourceConfigurationMode,
+		diffScope:               diffScope,
+		changes:                 changes,
+		cache:                   cache,
+		docsDir:                 s.docsDir,
+		semgrepEngine:           s.semgrepEngine,
+	})
 	if err != nil {
-		return nil, nil, errors.New("Error getting files to analyse: " + err.Error())
+		return nil, err
+	}
+	defer plan.close()
+
+	if plan.configurationFile == nil {
+		return []codacy.Result{}, nil
 	}
 
-	patternDescriptions, err := loadPatternDescriptions()
+	// The analysis ends before the deadline of the run, so the results it collected can be reported:
+	// Codacy discards the results of a run that returns an error or misses its deadline
+	var analysisCtx context.Context
+	var cancel context.CancelFunc
+	if s.failOnTimeout {
+		analysisCtx, cancel = context.WithCancel(ctx)
+	} else {
+		analysisCtx, cancel = withAnalysisDeadline(ctx)
+	}
+	defer cancel()
+	result, err := run(analysisCtx, plan)
+	if !s.failOnTimeout {
+		result, err = reportAnalysisTimeout(ctx, result, err)
+	}
+	if baselineFile := s.resolveBaselineFile(toolExecution.SourceDir); baselineFile != "" {
+		var baselineErr error
+		if result, baselineErr = filterBaselineIssues(result, baselineFile, plan.files); baselineErr != nil {
+			return nil, baselineErr
+		}
+	}
 	if err != nil {
-		return nil, nil, err
+		// When the analysis is cancelled, report the results that were already collected
+		return result, err
+	}
+
+	return result, nil
+}
+
+// reportAnalysisTimeout reports an analysis that ran out of time as completed, with a file error
+// for each file that wasn't analysed, as long as the run itself, with context ctx, didn't end.
+func reportAnalysisTimeout(ctx context.Context, results []codacy.Result, err error) ([]codacy.Result, error) {
+	var interrupted *interruptedError
+	if ctx.Err() != nil || !errors.As(err, &interrupted) || !errors.Is(err, context.DeadlineExceeded) {
+		return results, err
 	}
 
-	return configurationFile, patternDescriptions, nil
+	logrus.Warnf("%s: reporting %d files as not analysed", err.Error(), len(interrupted.skippedFiles))
+	for _, file := range interrupted.skippedFiles {
+		results = append(results, codacy.FileError{
+			File:    file,
+			Message: newFileErrorMessage(analysisTimeout, "the analysis ran out of time before the file was analysed"),
+		})
+	}
+	return results, nil
+}
+
+func (s codacySemgrep) resolveBaselineFile(sourceDir string) string {
+	if s.ignoreBaseline {
+		return ""
+	}
+	return environmentBaselineFile(s.baselineFile, sourceDir)
 }
 
-func loadPatternDescriptions() (*[]codacy.PatternDescription, error) {
-	// TODO: should respect cli flag for docs location
-	fileLocation := filepath.Join("/docs", "description", "description.json")
+// LoadPatternDescriptions reads the descriptions of the patterns of the docs directory, generated by docgen.
+func LoadPatternDescriptions(docsDir string) (*[]codacy.PatternDescription, error) {
+	fileLocation := filepath.Join(docsDir, "description", "description.json")
 
 	fileContent, err := os.ReadFile(fileLocation)
 	if err != nil {
@@ -76,15 +258,24 @@ func loadPatternDescriptions() (*[]codacy.PatternDescription, error) {
 	return &descriptions, nil
 }
 
-func run(configurationFile *os.File, toolExecution codacy.ToolExecution, patternDescriptions *[]codacy.PatternDescription) ([]codacy.Result, error) {
-	var results []codacy.Result
-	for language, files := range filesByLanguage {
-		result, err := executeCommandForFiles(configurationFile, toolExecution, patternDescriptions, language, files)
-		if err != nil {
-			return nil, err
+func run(ctx context.Context, plan *analysisPlan) ([]codacy.Result, error) {
+	budget := plan.engineLimits.global.budget()
+	cachedResults := plan.loadCachedResults()
+	results, err := scheduleJobs(ctx, plan.jobs(budget), budget, func(ctx context.Context, job semgrepJob, share resourceBudget) ([]codacy.Result, error) {
+		limits := plan.engineLimits.processLimits(job.language, share)
+		results, err := executeCommandForFiles(ctx, plan.semgrepEngine, plan.configurationFileOf(job), plan.sourceDir, plan.patternDescriptions, job.language, job.files, limits)
+		if err == nil {
+			plan.storeCachedResults(job, results)
+		}
+		return results, err
+	})
+	results = append(cachedResults, results...)
+	if plan.cache != nil {
+		if err := plan.cache.evict(); err != nil {
+			logrus.Warnf("semgrep cache eviction failed: %s", err.Error())
 		}
-		results = append(results, result...)
 	}
 
-	return results, nil
+	// Issues are fingerprinted before being filtered, so the fingerprint of an issue doesn't depend on the filters
+	return limitFileErrors(plan.filterChangedLines(plan.fingerprintIssues(plan.applySuggestions(deduplicateIssues(results))))), err
 }
@@ -0,0 +1,51 @@
+package tool
+
+import (
+	"context"
+	"testing"
+
+	codacy "github.com/codacy/codacy-engine-golang-seed/v6"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportAnalysisTimeout(t *testing.T) {
+	issue := codacy.Issue{PatternID: "python.exit", File: "app.py", Line: 2}
+	timedOut := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 0)
+		defer cancel()
+		<-ctx.Done()
+		return &interruptedError{completedJobs: 1, totalJobs: 2, skippedFiles: []string{"a.py", "b.py"}, err: ctx.Err()}
+	}
+
+	t.Run("analysis deadline reports the skipped files", func(t *testing.T) {
+		results, err := reportAnalysisTimeout(context.Background(), []codacy.Result{issue}, timedOut())
+
+		assert.NoError(t, err)
+		assert.Equal(t, []codacy.Result{
+			issue,
+			codacy.FileError{File: "a.py", Message: "Analysis timeout: the analysis ran out of time before the file was analysed"},
+			codacy.FileError{File: "b.py", Message: "Analysis timeout: the analysis ran out of time before the file was analysed"},
+		}, results)
+	})
+	t.Run("run deadline keeps the error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		results, err := reportAnalysisTimeout(ctx, []codacy.Result{issue}, timedOut())
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, []codacy.Result{issue}, results)
+	})
+	t.Run("cancellation keeps the error", func(t *testing.T) {
+		cancelled := &interruptedError{totalJobs: 1, skippedFiles: []string{"a.py"}, err: context.Canceled}
+
+		_, err := reportAnalysisTimeout(context.Background(), nil, cancelled)
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+	t.Run("other errors", func(t *testing.T) {
+		_, err := reportAnalysisTimeout(context.Background(), nil, assert.AnError)
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}